@@ -0,0 +1,104 @@
+package atlas
+
+import (
+	"image"
+)
+
+type PackOptions struct {
+	PowerOfTwo bool
+
+	// Padding is the number of transparent pixels reserved on every side of each frame, on top of the 1px gap between frames.
+	Padding int
+
+	// Align snaps the top-left corner of every frame to a multiple of this many pixels. 0 and 1 both mean no alignment.
+	Align int
+}
+
+// Packer places frames left to right in rows, starting a new row below everything placed so far when a frame doesn't fit.
+type Packer struct {
+	Width   int
+	Height  int
+	Options PackOptions
+
+	left   int
+	top    int
+	bottom int
+	used   image.Rectangle
+}
+
+// NewPacker returns a packer for an atlas of the given width. If height is 0, the atlas grows downwards without limit.
+func NewPacker(width int, height int, opts PackOptions) *Packer {
+	return &Packer{Width: width, Height: height, Options: opts}
+}
+
+func (p *Packer) align(v int) int {
+	a := p.Options.Align
+	if a <= 1 {
+		return v
+	}
+	return (v + a - 1) / a * a
+}
+
+// position returns where a cell for a frame of the given size would go, and whether it starts a new row.
+func (p *Packer) position(size image.Point) (image.Point, bool) {
+	pad := p.Options.Padding
+
+	left := p.align(p.left+pad) - pad
+	if left+size.X+2*pad <= p.Width {
+		return image.Point{left, p.align(p.top+pad) - pad}, false
+	}
+	return image.Point{p.align(pad) - pad, p.align(p.bottom+1+pad) - pad}, true
+}
+
+// Fits reports whether a frame of the given size can be placed without exceeding the packer's height.
+func (p *Packer) Fits(size image.Point) bool {
+	if p.Height == 0 || size.X <= 0 || size.Y <= 0 {
+		return true
+	}
+
+	pos, _ := p.position(size)
+	return pos.Y+size.Y+2*p.Options.Padding <= p.Height
+}
+
+// Place reserves room for a frame of the given size and returns where the frame itself goes, excluding any padding.
+func (p *Packer) Place(size image.Point) image.Rectangle {
+	if size.X <= 0 || size.Y <= 0 {
+		return image.Rectangle{image.Point{p.left, p.top}, image.Point{p.left + size.X, p.top + size.Y}}
+	}
+
+	pad := p.Options.Padding
+	cell := size.Add(image.Point{2 * pad, 2 * pad})
+
+	pos, newRow := p.position(size)
+	if newRow {
+		p.top = pos.Y
+	}
+
+	outer := image.Rectangle{pos, pos.Add(cell)}
+
+	p.left = outer.Max.X + 1
+	if outer.Max.Y > p.bottom {
+		p.bottom = outer.Max.Y
+	}
+	p.used = p.used.Union(outer)
+
+	return outer.Inset(pad)
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// Size returns the dimensions of an atlas that holds everything placed so far.
+func (p *Packer) Size() image.Point {
+	size := p.used.Max
+	if p.Options.PowerOfTwo && !p.used.Empty() {
+		size.X = nextPowerOfTwo(size.X)
+		size.Y = nextPowerOfTwo(size.Y)
+	}
+	return size
+}
@@ -0,0 +1,96 @@
+package atlas
+
+import (
+	"image"
+	"testing"
+)
+
+// testSizes are frame sizes of the kinds a sprite has, in no particular order, so the packer has to start new rows.
+var testSizes = []image.Point{
+	{16, 16}, {7, 30}, {40, 9}, {3, 3}, {24, 24}, {33, 5}, {8, 8}, {1, 17}, {50, 12}, {12, 50},
+}
+
+func place(opts PackOptions) (*Packer, []image.Rectangle) {
+	p := NewPacker(64, 0, opts)
+	rects := make([]image.Rectangle, len(testSizes))
+	for i, size := range testSizes {
+		rects[i] = p.Place(size)
+	}
+	return p, rects
+}
+
+func TestPackerPowerOfTwo(t *testing.T) {
+	for _, opts := range []PackOptions{{}, {PowerOfTwo: true}, {PowerOfTwo: true, Padding: 2}} {
+		p, rects := place(opts)
+		size := p.Size()
+
+		var used image.Rectangle
+		for i, r := range rects {
+			if r.Size() != testSizes[i] {
+				t.Errorf("%+v: frame %d placed as %s, want size %s", opts, i, r, testSizes[i])
+			}
+			if !r.In(image.Rectangle{Max: size}) {
+				t.Errorf("%+v: frame %d at %s is outside the %s atlas", opts, i, r, size)
+			}
+			for j := 0; j < i; j++ {
+				if r.Overlaps(rects[j]) {
+					t.Errorf("%+v: frame %d at %s overlaps frame %d at %s", opts, i, r, j, rects[j])
+				}
+			}
+			used = used.Union(r.Inset(-opts.Padding))
+		}
+
+		if !opts.PowerOfTwo {
+			if size != used.Max {
+				t.Errorf("%+v: Size() = %s, want %s, just what's used", opts, size, used.Max)
+			}
+			continue
+		}
+		for _, v := range []int{size.X, size.Y} {
+			if v&(v-1) != 0 {
+				t.Errorf("%+v: Size() = %s, which isn't a power of two", opts, size)
+			}
+		}
+		if size.X < used.Max.X || size.Y < used.Max.Y || size.X >= 2*used.Max.X || size.Y >= 2*used.Max.Y {
+			t.Errorf("%+v: Size() = %s, want the next powers of two up from %s", opts, size, used.Max)
+		}
+	}
+
+	p := NewPacker(64, 0, PackOptions{PowerOfTwo: true})
+	if size := p.Size(); size != (image.Point{}) {
+		t.Errorf("Size() of an empty atlas = %s, want 0x0", size)
+	}
+}
+
+func TestPackerAlign(t *testing.T) {
+	for _, align := range []int{4, 8} {
+		for _, pad := range []int{0, 1, 3} {
+			opts := PackOptions{Align: align, Padding: pad}
+			_, rects := place(opts)
+			for i, r := range rects {
+				if r.Min.X%align != 0 || r.Min.Y%align != 0 {
+					t.Errorf("%+v: frame %d at %s isn't aligned to %d", opts, i, r, align)
+				}
+				if r.Size() != testSizes[i] {
+					t.Errorf("%+v: frame %d placed as %s, want size %s", opts, i, r, testSizes[i])
+				}
+				for j := 0; j < i; j++ {
+					if r.Overlaps(rects[j]) {
+						t.Errorf("%+v: frame %d at %s overlaps frame %d at %s", opts, i, r, j, rects[j])
+					}
+				}
+			}
+		}
+	}
+
+	// 0 and 1 both leave frames where they'd be without alignment.
+	_, unaligned := place(PackOptions{})
+	for _, align := range []int{0, 1} {
+		_, rects := place(PackOptions{Align: align})
+		for i := range rects {
+			if rects[i] != unaligned[i] {
+				t.Errorf("Align %d: frame %d at %s, want %s as without alignment", align, i, rects[i], unaligned[i])
+			}
+		}
+	}
+}
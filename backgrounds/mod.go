@@ -0,0 +1,161 @@
+package backgrounds
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/gbarom/lz77"
+)
+
+// ROMInfo says where a background's data is. Like battletiles.ROMInfo, the offsets are of ROM pointers to the data rather than of the data itself. A pointer with the high bit set is LZ77 compressed.
+type ROMInfo struct {
+	TilesetOffset int64
+	TilemapOffset int64
+	PaletteOffset int64
+
+	// Width and Height are in tiles.
+	Width  int
+	Height int
+}
+
+// KnownBackgrounds maps ROM IDs to backgrounds by name. No offsets have been confirmed yet, so it's empty: pass a ROMInfo found by hand to Read in the meantime.
+var KnownBackgrounds = map[string]map[string]ROMInfo{}
+
+func FindROMInfo(romID string, name string) *ROMInfo {
+	ri, ok := KnownBackgrounds[romID][name]
+	if !ok {
+		return nil
+	}
+	return &ri
+}
+
+// ScreenEntry is one entry of a GBA text-mode tilemap.
+type ScreenEntry struct {
+	TileIndex int
+	Flip      sprites.Flip
+	Palbank   int
+}
+
+func DecodeScreenEntry(v uint16) ScreenEntry {
+	var flip sprites.Flip
+	if v&0x0400 != 0 {
+		flip |= sprites.FlipH
+	}
+	if v&0x0800 != 0 {
+		flip |= sprites.FlipV
+	}
+	return ScreenEntry{int(v & 0x03ff), flip, int(v >> 12)}
+}
+
+// readPointedData follows the ROM pointer at offset and returns the data it points to, decompressing it if needed. Uncompressed data is assumed to be size bytes long.
+func readPointedData(r io.ReadSeeker, offset int64, size int) ([]byte, error) {
+	if _, err := r.Seek(offset, os.SEEK_SET); err != nil {
+		return nil, fmt.Errorf("%w while seeking to pointer", err)
+	}
+
+	var ptr uint32
+	if err := binary.Read(r, binary.LittleEndian, &ptr); err != nil {
+		return nil, fmt.Errorf("%w while reading pointer", err)
+	}
+
+	if ptr&0x08000000 == 0 {
+		return nil, fmt.Errorf("%w: pointer 0x%08x is not a ROM pointer", sprites.ErrBadPointer, ptr)
+	}
+
+	if _, err := r.Seek(int64(ptr & ^uint32(0x88000000)), os.SEEK_SET); err != nil {
+		return nil, fmt.Errorf("%w while seeking to data at pointer 0x%08x", err, ptr)
+	}
+
+	if ptr&0x80000000 != 0 {
+		buf, err := lz77.Decompress(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w while decompressing data at pointer 0x%08x", err, ptr)
+		}
+		return buf, nil
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w while reading data at pointer 0x%08x", err, ptr)
+	}
+	return buf, nil
+}
+
+// Read assembles a background from its tilemap, its 4bpp tileset and its 16 palbanks.
+func Read(r io.ReadSeeker, ri ROMInfo) (*image.Paletted, error) {
+	rawMap, err := readPointedData(r, ri.TilemapOffset, ri.Width*ri.Height*2)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading tilemap", err)
+	}
+
+	if len(rawMap) < ri.Width*ri.Height*2 {
+		return nil, fmt.Errorf("%w: tilemap is %d bytes, need %d", sprites.ErrTruncated, len(rawMap), ri.Width*ri.Height*2)
+	}
+
+	entries := make([]ScreenEntry, ri.Width*ri.Height)
+	numTiles := 0
+	for i := range entries {
+		entries[i] = DecodeScreenEntry(binary.LittleEndian.Uint16(rawMap[i*2:]))
+		if entries[i].TileIndex >= numTiles {
+			numTiles = entries[i].TileIndex + 1
+		}
+	}
+
+	rawTiles, err := readPointedData(r, ri.TilesetOffset, numTiles*8*8/2)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading tileset", err)
+	}
+
+	rawPalette, err := readPointedData(r, ri.PaletteOffset, 16*16*2)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading palette", err)
+	}
+
+	n := len(rawPalette) / 2
+	if n > 256 {
+		n = 256
+	}
+
+	palette, err := sprites.DecodePalette(rawPalette, n)
+	if err != nil {
+		return nil, fmt.Errorf("%w while decoding palette", err)
+	}
+
+	// Pad out to all 16 palbanks, so every pixel index is in range even if the palette data is short.
+	for len(palette) < 256 {
+		palette = append(palette, color.RGBA{})
+	}
+
+	// Entry 0 of every palbank is transparent.
+	for i := 0; i < len(palette); i += 16 {
+		palette[i] = color.RGBA{}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, ri.Width*8, ri.Height*8), palette)
+	for i, ent := range entries {
+		start := ent.TileIndex * 8 * 8 / 2
+		if start+8*8/2 > len(rawTiles) {
+			return nil, fmt.Errorf("%w: screen entry %d uses tile %d, but the tileset only has %d", sprites.ErrOutOfRange, i, ent.TileIndex, len(rawTiles)/(8*8/2))
+		}
+
+		tileImg := sprites.DecodeTile4bpp(rawTiles[start:start+8*8/2], nil, ent.Flip&sprites.FlipH != 0, ent.Flip&sprites.FlipV != 0)
+
+		for k, p := range tileImg.Pix {
+			if p != 0 {
+				tileImg.Pix[k] = p + uint8(16*ent.Palbank)
+			}
+		}
+
+		x := (i % ri.Width) * 8
+		y := (i / ri.Width) * 8
+		paletted.DrawOver(img, image.Rect(x, y, x+8, y+8), tileImg, image.Point{})
+	}
+
+	return img, nil
+}
@@ -1,16 +1,14 @@
 package battletiles
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
 	"io"
-	"log"
 	"os"
 
-	"github.com/murkland/gbarom/bgr555"
+	"github.com/murkland/bnrom/sprites"
 )
 
 const paletteOffsetPtr = 0x0000C16C
@@ -67,18 +65,12 @@ func ReadPalbanks(r io.ReadSeeker, ri ROMInfo) ([]color.Palette, error) {
 	for i := 0; i < 45; i++ {
 		var raw [16 * 2]byte
 		if _, err := io.ReadFull(r, raw[:]); err != nil {
-			log.Fatalf("%s", err)
+			return nil, fmt.Errorf("%w while reading palbank %d", err, i)
 		}
 
-		var palette color.Palette
-		palR := bytes.NewBuffer(raw[:])
-		for j := 0; j < 16; j++ {
-			var c uint16
-			if err := binary.Read(palR, binary.LittleEndian, &c); err != nil {
-				log.Fatalf("%s", err)
-			}
-
-			palette = append(palette, bgr555.ToRGBA(c))
+		palette, err := sprites.DecodePalette(raw[:], 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w while decoding palbank %d", err, i)
 		}
 		palette[0] = color.RGBA{}
 
@@ -1,7 +1,6 @@
 package battletiles
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"image"
@@ -147,14 +146,7 @@ func ReadTiles(r io.ReadSeeker, ri ROMInfo) ([]*image.Paletted, error) {
 			}
 			tIndex--
 
-			tileImg, err := sprites.ReadTile(bytes.NewBuffer(rawTiles[tIndex*8*8/2:(tIndex+1)*8*8/2]), image.Rect(0, 0, 8, 8))
-			if err != nil {
-				return nil, fmt.Errorf("%w while reading tile %d", err, tIndex)
-			}
-
-			if flipH {
-				paletted.FlipHorizontal(tileImg)
-			}
+			tileImg := sprites.DecodeTile4bpp(rawTiles[tIndex*8*8/2:(tIndex+1)*8*8/2], nil, flipH, false)
 
 			x := (i % 5) * 8
 			y := (i / 5) * 8
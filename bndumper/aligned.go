@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"math"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
+)
+
+func alignedFilename(outFn string, idx int, animIdx int, name string) string {
+	if name != "" {
+		return fmt.Sprintf("%s/%04d_%s.gif", outFn, idx, name)
+	}
+	return fmt.Sprintf("%s/%04d_%02d.gif", outFn, idx, animIdx)
+}
+
+// makeAlignedGIF renders an animation onto one canvas big enough for every frame, placing each frame so its origin lands on the same pixel. Unlike the sheet, which trims every frame on its own, this keeps the motion between frames.
+func makeAlignedGIF(anim sprites.Animation) (*gif.GIF, error) {
+	imgs := make([]*image.Paletted, len(anim.Frames))
+	origins := make([]image.Point, len(anim.Frames))
+
+	for i, frame := range anim.Frames {
+		img, origin, err := renderTrimmedFrame(frame, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w while rendering frame %d", err, i)
+		}
+		imgs[i] = img
+		origins[i] = origin
+	}
+
+	canvas := anim.UnionBounds()
+
+	if canvas.Empty() {
+		return nil, nil
+	}
+
+	g := &gif.GIF{LoopCount: -1}
+	for i, frame := range anim.Frames {
+		dst := image.NewPaletted(image.Rect(0, 0, canvas.Dx(), canvas.Dy()), imgs[i].Palette)
+		at := origins[i].Mul(-1).Sub(canvas.Min)
+		paletted.DrawOver(dst, imgs[i].Rect.Add(at), imgs[i], image.Point{})
+
+		g.Image = append(g.Image, dst)
+		// GIF delays are in hundredths of a second.
+		g.Delay = append(g.Delay, int(math.Round(float64(frame.Delay)*100/sprites.FrameRate)))
+		g.Disposal = append(g.Disposal, gif.DisposalBackground)
+
+		if frame.Action&sprites.FrameActionLoop == sprites.FrameActionLoop {
+			g.LoopCount = 0
+		}
+	}
+
+	return g, nil
+}
+
+// writeAlignedAnims writes every animation of a sprite as a GIF with all frames anchored on their origins.
+func writeAlignedAnims(outFn string, idx int, anims []sprites.Animation) error {
+	for animIdx, anim := range anims {
+		g, err := makeAlignedGIF(anim)
+		if err != nil {
+			return fmt.Errorf("%w while rendering animation %d", err, animIdx)
+		}
+
+		if g == nil {
+			continue
+		}
+
+		if err := writeFileAtomic(alignedFilename(outFn, idx, animIdx, anim.Name), func(w io.Writer) error {
+			return gif.EncodeAll(w, g)
+		}); err != nil {
+			return fmt.Errorf("%w while writing animation %d", err, animIdx)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+// animNames holds the -anim_names mapping, by sprite then by animation index.
+var animNames map[int]map[int]string
+
+// readAnimNames reads a CSV file of sprite,animation,name rows.
+func readAnimNames(fn string) (map[int]map[int]string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	cr.FieldsPerRecord = 3
+	cr.Comment = '#'
+
+	names := map[int]map[int]string{}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		spriteIdx, err := strconv.Atoi(strings.TrimSpace(rec[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%w while parsing sprite on line %d", err, line)
+		}
+
+		animIdx, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%w while parsing animation on line %d", err, line)
+		}
+
+		// Names end up in file names and in the fanim chunk, which only has a byte for the length.
+		name := strings.TrimSpace(rec[2])
+		if name == "" || len(name) > 255 || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("bad animation name %q on line %d", name, line)
+		}
+
+		if names[spriteIdx] == nil {
+			names[spriteIdx] = map[int]string{}
+		}
+		names[spriteIdx][animIdx] = name
+	}
+
+	return names, nil
+}
+
+// nameAnims sets the names given by -anim_names on a sprite's animations. Animations without one keep an empty name.
+func nameAnims(idx int, anims []sprites.Animation) {
+	for animIdx, name := range animNames[idx] {
+		if animIdx >= 0 && animIdx < len(anims) {
+			anims[animIdx].Name = name
+		}
+	}
+}
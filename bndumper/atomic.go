@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes fn by calling write on a temporary file next to it, then renaming that over fn once everything has been written. Anything reading fn, like -skip_existing, sees either the old file or the complete new one, never a half-written one.
+func writeFileAtomic(fn string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpFn := f.Name()
+
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmpFn)
+		return err
+	}
+
+	// CreateTemp makes files only the owner can read, unlike os.Create.
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		os.Remove(tmpFn)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpFn)
+		return err
+	}
+
+	if err := os.Rename(tmpFn, fn); err != nil {
+		os.Remove(tmpFn)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicFailure(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "0000.png")
+	if err := os.WriteFile(fn, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	errWrite := errors.New("write failed")
+	err := writeFileAtomic(fn, func(w io.Writer) error {
+		// Write part of the new file first, as a failing encoder would.
+		if _, err := w.Write([]byte("half of the new")); err != nil {
+			return err
+		}
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) {
+		t.Errorf("writeFileAtomic = %v, want the callback's error", err)
+	}
+
+	got, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old" {
+		t.Errorf("target holds %q after a failed write, want it untouched", got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "0000.png" {
+			t.Errorf("%s was left behind after a failed write", e.Name())
+		}
+	}
+
+	// A missing target is left missing.
+	missing := filepath.Join(dir, "0001.png")
+	if err := writeFileAtomic(missing, func(w io.Writer) error { return errWrite }); !errors.Is(err, errWrite) {
+		t.Errorf("writeFileAtomic = %v, want the callback's error", err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("failed write created %s", missing)
+	}
+}
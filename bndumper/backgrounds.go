@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+
+	"github.com/murkland/bnrom/backgrounds"
+)
+
+// parseBackgroundInfo parses -background: the offsets of the tileset, tilemap and palette pointers in hex, then the width and height in tiles.
+func parseBackgroundInfo(s string) (backgrounds.ROMInfo, error) {
+	var ri backgrounds.ROMInfo
+	if _, err := fmt.Sscanf(s, "%x,%x,%x,%d,%d", &ri.TilesetOffset, &ri.TilemapOffset, &ri.PaletteOffset, &ri.Width, &ri.Height); err != nil {
+		return ri, fmt.Errorf("%w while parsing background %q, expected tileset,tilemap,palette,width,height", err, s)
+	}
+	return ri, nil
+}
+
+func dumpBackground(r io.ReadSeeker, ri backgrounds.ROMInfo, outFn string) error {
+	img, err := backgrounds.Read(r, ri)
+	if err != nil {
+		return err
+	}
+	img.Palette = colorAdjust.Palette(img.Palette)
+
+	return writeFileAtomic(outFn, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
@@ -4,14 +4,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"io"
-	"os"
 
 	"github.com/murkland/bnrom/battletiles"
 	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
 	"github.com/murkland/gbarom"
 	"github.com/murkland/pngchunks"
 	"golang.org/x/sync/errgroup"
@@ -35,6 +36,7 @@ func dumpBattletiles(r io.ReadSeeker, outFn string) error {
 
 	redPal, m := battletiles.ConsolidatePalbank(palbanks, battletiles.RedTileByIndex)
 	bluePal, _ := battletiles.ConsolidatePalbank(palbanks, battletiles.BlueTileByIndex)
+	redPal, bluePal = colorAdjust.Palette(redPal), colorAdjust.Palette(bluePal)
 
 	tiles, err := battletiles.ReadTiles(r, *info)
 	if err != nil {
@@ -58,106 +60,110 @@ func dumpBattletiles(r io.ReadSeeker, outFn string) error {
 	img = img.SubImage(paletted.FindTrim(img)).(*image.Paletted)
 
 	img.Palette = redPal
-	outf, err := os.Create(outFn)
-	if err != nil {
-		return err
-	}
+	return writeFileAtomic(outFn, func(outf io.Writer) error {
+		pipeR, pipeW := io.Pipe()
+		defer pipeR.Close()
 
-	pipeR, pipeW := io.Pipe()
+		var g errgroup.Group
 
-	var g errgroup.Group
+		g.Go(func() error {
+			defer pipeW.Close()
+			if err := png.Encode(pipeW, img); err != nil {
+				return err
+			}
+			return nil
+		})
 
-	g.Go(func() error {
-		defer pipeW.Close()
-		if err := png.Encode(pipeW, img); err != nil {
+		pngr, err := pngchunks.NewReader(pipeR)
+		if err != nil {
 			return err
 		}
-		return nil
-	})
-
-	pngr, err := pngchunks.NewReader(pipeR)
-	if err != nil {
-		return err
-	}
-
-	pngw, err := pngchunks.NewWriter(outf)
-	if err != nil {
-		return err
-	}
 
-	var metaWritten bool
-	for {
-		chunk, err := pngr.NextChunk()
+		pngw, err := pngchunks.NewWriter(outf)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
+			return err
 		}
 
-		if chunk.Type() == "IDAT" && !metaWritten {
-			// Pack metadata in here.
-			{
-				var buf bytes.Buffer
-				buf.WriteString("alt")
-				buf.WriteByte('\x00')
-				buf.WriteByte('\x08')
-				for _, c := range bluePal {
-					binary.Write(&buf, binary.LittleEndian, c.(color.RGBA))
-					buf.WriteByte('\xff')
-					buf.WriteByte('\xff')
-				}
-				if err := pngw.WriteChunk(int32(buf.Len()), "sPLT", bytes.NewBuffer(buf.Bytes())); err != nil {
-					return err
+		var metaWritten, ended bool
+		for {
+			chunk, err := pngr.NextChunk()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					if !ended {
+						return fmt.Errorf("%w: png ends before its IEND chunk", io.ErrUnexpectedEOF)
+					}
+					break
 				}
+				return fmt.Errorf("%w while reading png chunk", err)
 			}
-
-			{
-				var buf bytes.Buffer
-				buf.WriteString("fctrl")
-				buf.WriteByte('\x00')
-				buf.WriteByte('\xff')
-				for tileIdx, fi := range battletiles.FrameInfos {
-					action := uint8(0)
-					if fi.IsEnd {
-						action = 0x01
+			ended = chunk.Type() == "IEND"
+
+			if chunk.Type() == "IDAT" && !metaWritten {
+				// Pack metadata in here.
+				{
+					var buf bytes.Buffer
+					buf.WriteString("alt")
+					buf.WriteByte('\x00')
+					buf.WriteByte('\x08')
+					for _, c := range bluePal {
+						binary.Write(&buf, binary.LittleEndian, color.RGBAModel.Convert(c).(color.RGBA))
+						buf.WriteByte('\xff')
+						buf.WriteByte('\xff')
+					}
+					if err := pngw.WriteChunk(int32(buf.Len()), "sPLT", bytes.NewBuffer(buf.Bytes())); err != nil {
+						return err
 					}
-
-					x := (tileIdx % 9) * battletiles.Width
-					y := (tileIdx / 9) * battletiles.Height
-
-					binary.Write(&buf, binary.LittleEndian, fctrlFrameInfo{
-						int16(x),
-						int16(y),
-						int16(x + battletiles.Width),
-						int16(y + battletiles.Height),
-						int16(0),
-						int16(0),
-						uint8(fi.Delay),
-						action,
-					})
-
-					tileIdx++
 				}
-				if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
-					return err
+
+				{
+					var buf bytes.Buffer
+					buf.WriteString("fctrl")
+					buf.WriteByte('\x00')
+					buf.WriteByte('\xff')
+					buf.WriteByte(fctrlVersion)
+					for tileIdx, fi := range battletiles.FrameInfos {
+						action := sprites.FrameActionNext
+						if fi.IsEnd {
+							action = sprites.FrameActionLoop
+						}
+
+						x := (tileIdx % 9) * battletiles.Width
+						y := (tileIdx / 9) * battletiles.Height
+
+						binary.Write(&buf, binary.LittleEndian, fctrlFrameInfo{
+							int16(x),
+							int16(y),
+							int16(x + battletiles.Width),
+							int16(y + battletiles.Height),
+							int16(0),
+							int16(0),
+							uint16(fi.Delay),
+							action,
+						})
+
+						tileIdx++
+					}
+					if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
+						return err
+					}
 				}
+
+				metaWritten = true
 			}
 
-			metaWritten = true
-		}
+			if err := pngw.WriteChunk(chunk.Length(), chunk.Type(), chunk); err != nil {
+				return err
+			}
 
-		if err := pngw.WriteChunk(chunk.Length(), chunk.Type(), chunk); err != nil {
-			return err
+			if err := chunk.Close(); err != nil {
+				return err
+			}
 		}
 
-		if err := chunk.Close(); err != nil {
+		if err := g.Wait(); err != nil {
 			return err
 		}
-	}
 
-	if err := g.Wait(); err != nil {
-		return err
-	}
-
-	return nil
+		return nil
+	})
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/gbarom"
+)
+
+// checkSprite decodes sprite idx and builds its sheet in memory, turning a panic anywhere on the way into an error.
+func checkSprite(sr *sprites.Reader, idx int) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	anims, err := sr.Sprite(idx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := buildSheet(idx, anims, nil); err != nil {
+		return fmt.Errorf("%w while rendering", err)
+	}
+	return nil
+}
+
+// checkSprites decodes and renders every sprite in the ROM without writing anything, and returns how many failed.
+func checkSprites(ctx context.Context, r io.ReadSeeker) (int, error) {
+	romID, err := gbarom.ReadROMID(r)
+	if err != nil {
+		return 0, err
+	}
+
+	info := findSpriteInfo(romID)
+	if info == nil {
+		return 0, errors.New("unsupported game")
+	}
+
+	sr := sprites.NewReader(r, *info)
+
+	passed, skipped, failed := 0, 0, 0
+	bar := newProgress("check", info.Count)
+	for i := 0; i < info.Count; i++ {
+		if err := ctx.Err(); err != nil {
+			return 0, fmt.Errorf("%w while checking sprites", err)
+		}
+
+		bar.step(i)
+		if *spriteF >= 0 && i != *spriteF {
+			skipped++
+			continue
+		}
+
+		if *skipEmptyF {
+			if _, err := r.Seek(info.Offset+int64(i)*info.EntrySize(), io.SeekStart); err != nil {
+				return 0, err
+			}
+			ptr, err := info.ReadPointer(r)
+			if err != nil {
+				return 0, fmt.Errorf("%w while reading pointer for sprite %04d", err, i)
+			}
+			if ptr == info.PointerBase {
+				bar.report(i, "empty", nil)
+				skipped++
+				continue
+			}
+		}
+
+		if err := checkSprite(sr, i); err != nil {
+			warnf("sprite %04d: %s", i, err)
+			bar.report(i, "failed", err)
+			failed++
+			continue
+		}
+		bar.report(i, "passed", nil)
+		passed++
+	}
+
+	infof("Check: %d passed, %d skipped, %d failed", passed, skipped, failed)
+	return failed, nil
+}
@@ -11,9 +11,9 @@ import (
 	"io"
 	"os"
 
-	"github.com/schollz/progressbar/v3"
 	"github.com/murkland/bnrom/chips"
 	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
 	"github.com/murkland/gbarom"
 	"github.com/murkland/pngchunks"
 	"golang.org/x/sync/errgroup"
@@ -41,11 +41,9 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 
 	chipInfos := make([]chips.ChipInfo, info.Count)
 
-	bar1 := progressbar.Default(int64(info.Count))
-	bar1.Describe("decode")
+	bar1 := newProgress("decode", info.Count)
 	for i := 0; i < len(chipInfos); i++ {
-		bar1.Add(1)
-		bar1.Describe(fmt.Sprintf("decode: %04d", i))
+		bar1.step(i)
 		ci, err := chips.ReadChipInfo(r)
 		if err != nil {
 			return err
@@ -57,11 +55,11 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 	if err != nil {
 		return err
 	}
+	iconPalette = colorAdjust.Palette(iconPalette)
 
 	ereaderGigaPalette := chips.EReaderGigaPalette(romTitle)
 
-	bar2 := progressbar.Default(int64(len(chipInfos)))
-	bar2.Describe("dump")
+	bar2 := newProgress("dump", len(chipInfos))
 
 	numRows := (len(chipInfos) + 10 - 1) / 10
 
@@ -69,8 +67,7 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 	iconsImg := image.NewPaletted(image.Rect(0, 0, chips.IconWidth*10, chips.IconHeight*numRows), iconPalette)
 
 	for i, ci := range chipInfos {
-		bar2.Add(1)
-		bar2.Describe(fmt.Sprintf("dump: %04d", i))
+		bar2.step(i)
 
 		chipIconImg, err := chips.ReadChipIcon(r, ci)
 		if err != nil {
@@ -86,18 +83,15 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 		if err != nil {
 			return err
 		}
+		chipImg.Palette = colorAdjust.Palette(chipImg.Palette)
 
 		draw.Draw(img, image.Rect(x*chips.Width, y*chips.Height, (x+1)*chips.Width, (y+1)*chips.Height), chipImg, image.Point{}, draw.Over)
 	}
 
-	if err := func() error {
-		f, err := os.Create(chipsOutFn)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+	if err := writeFileAtomic(chipsOutFn, func(f io.Writer) error {
 
 		pipeR, pipeW := io.Pipe()
+		defer pipeR.Close()
 
 		var g errgroup.Group
 
@@ -119,14 +113,19 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 			return err
 		}
 
-		var metaWritten bool
+		var metaWritten, ended bool
 		for {
 			chunk, err := pngr.NextChunk()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
+					if !ended {
+						return fmt.Errorf("%w: png ends before its IEND chunk", io.ErrUnexpectedEOF)
+					}
 					break
 				}
+				return fmt.Errorf("%w while reading png chunk", err)
 			}
+			ended = chunk.Type() == "IEND"
 
 			if chunk.Type() == "IDAT" && !metaWritten {
 				// Pack metadata in here.
@@ -135,6 +134,7 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 					buf.WriteString("fctrl")
 					buf.WriteByte('\x00')
 					buf.WriteByte('\xff')
+					buf.WriteByte(fctrlVersion)
 					for i := 0; i < len(chipInfos); i++ {
 						x := i % 10
 						y := i / 10
@@ -146,8 +146,8 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 							int16((y + 1) * chips.Height),
 							int16(0),
 							int16(0),
-							uint8(1),
-							2,
+							1,
+							sprites.FrameActionStop,
 						})
 					}
 					if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
@@ -172,18 +172,14 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 		}
 
 		return nil
-	}(); err != nil {
+	}); err != nil {
 		return err
 	}
 
-	if err := func() error {
-		f, err := os.Create(iconsOutFn)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+	if err := writeFileAtomic(iconsOutFn, func(f io.Writer) error {
 
 		pipeR, pipeW := io.Pipe()
+		defer pipeR.Close()
 
 		var g errgroup.Group
 
@@ -205,14 +201,19 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 			return err
 		}
 
-		var metaWritten bool
+		var metaWritten, ended bool
 		for {
 			chunk, err := pngr.NextChunk()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
+					if !ended {
+						return fmt.Errorf("%w: png ends before its IEND chunk", io.ErrUnexpectedEOF)
+					}
 					break
 				}
+				return fmt.Errorf("%w while reading png chunk", err)
 			}
+			ended = chunk.Type() == "IEND"
 
 			if chunk.Type() == "IDAT" && !metaWritten {
 				// Pack metadata in here.
@@ -221,6 +222,7 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 					buf.WriteString("fctrl")
 					buf.WriteByte('\x00')
 					buf.WriteByte('\xff')
+					buf.WriteByte(fctrlVersion)
 					for i := 0; i < len(chipInfos); i++ {
 						x := i % 10
 						y := i / 10
@@ -232,8 +234,8 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 							int16((y + 1) * chips.IconHeight),
 							int16(0),
 							int16(0),
-							uint8(1),
-							2,
+							1,
+							sprites.FrameActionStop,
 						})
 					}
 					if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
@@ -258,7 +260,7 @@ func dumpChips(r io.ReadSeeker, chipsOutFn string, iconsOutFn string) error {
 		}
 
 		return nil
-	}(); err != nil {
+	}); err != nil {
 		return err
 	}
 
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/murkland/bnrom/enemies"
+	"github.com/murkland/bnrom/sprites"
+)
+
+// configOffset is an offset given either as a JSON number or, since JSON has no hex literals, as a string like "0x31CEC".
+type configOffset int64
+
+func (o *configOffset) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("offset must be a number or a string: %s", b)
+		}
+		*o = configOffset(n)
+		return nil
+	}
+
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("%w while parsing offset %q", err, s)
+	}
+	*o = configOffset(n)
+	return nil
+}
+
+type configKindRange struct {
+	Start int          `json:"start"`
+	Count int          `json:"count"`
+	Kind  sprites.Kind `json:"kind"`
+}
+
+type configEnemyTable struct {
+	Offset       configOffset `json:"offset"`
+	Count        int          `json:"count"`
+	EntrySize    int64        `json:"entry_size"`
+	NameOffset   int64        `json:"name_offset"`
+	SpriteOffset int64        `json:"sprite_offset"`
+	// Source says where the layout comes from. It's required, because a layout that's only a guess gives every sprite the wrong enemies.
+	Source string `json:"source"`
+}
+
+type configGame struct {
+	Title        string       `json:"title"`
+	Offset       configOffset `json:"offset"`
+	Count        int          `json:"count"`
+	PointerBytes int          `json:"pointer_bytes"`
+	PointerBase  configOffset `json:"pointer_base"`
+	// PointerStyle is "gba_absolute", the default, or "rom_relative"; -find_table prints which one a table is in.
+	PointerStyle sprites.PointerStyle `json:"pointer_style"`
+	// Kinds labels ranges of sprites as "battle", "overworld" or "effect", which nothing in the sprite data says.
+	Kinds []configKindRange `json:"kinds"`
+	// Enemies is the game's enemy table, which labels sprites with the enemies that use them.
+	Enemies *configEnemyTable `json:"enemies"`
+}
+
+type config struct {
+	Games   map[string]configGame  `json:"games"`
+	Options map[string]interface{} `json:"options"`
+}
+
+// loadConfig reads a JSON config file that adds games, and their enemy tables, to the registries and sets options by flag name. Flags given on the command line win over options in the file.
+func loadConfig(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+
+	var cfg config
+	if err := dec.Decode(&cfg); err != nil {
+		return err
+	}
+
+	for romID, game := range cfg.Games {
+		if len(romID) != 4 {
+			return fmt.Errorf("game %q: rom id must be 4 characters", romID)
+		}
+		if game.Offset <= 0 {
+			return fmt.Errorf("game %s: offset is required", romID)
+		}
+		if game.Count <= 0 {
+			return fmt.Errorf("game %s: count is required", romID)
+		}
+		if game.PointerBytes < 0 || game.PointerBytes > 4 {
+			return fmt.Errorf("game %s: pointer_bytes must be between 1 and 4", romID)
+		}
+		if game.PointerBase < 0 || game.PointerBase > math.MaxUint32 {
+			return fmt.Errorf("game %s: pointer_base must fit in 32 bits", romID)
+		}
+
+		var kinds []sprites.KindRange
+		for _, kr := range game.Kinds {
+			if kr.Start < 0 || kr.Count <= 0 || kr.Start+kr.Count > game.Count {
+				return fmt.Errorf("game %s: kind range of %d sprites from %d isn't in the table", romID, kr.Count, kr.Start)
+			}
+			kinds = append(kinds, sprites.KindRange{Start: kr.Start, Count: kr.Count, Kind: kr.Kind})
+		}
+
+		if et := game.Enemies; et != nil {
+			if et.Offset <= 0 || et.Count <= 0 {
+				return fmt.Errorf("game %s: enemies needs an offset and a count", romID)
+			}
+			if et.Source == "" {
+				return fmt.Errorf("game %s: enemies needs a source for its layout", romID)
+			}
+			enemies.KnownTables[romID] = enemies.ROMInfo{
+				Offset: int64(et.Offset),
+				Count:  et.Count,
+				Layout: enemies.Layout{EntrySize: et.EntrySize, NameOffset: et.NameOffset, SpriteOffset: et.SpriteOffset},
+				Source: et.Source,
+			}
+		}
+
+		title := game.Title
+		if title == "" {
+			title = romID
+		}
+		sprites.KnownGames[romID] = sprites.GameInfo{Title: title, ROMInfo: sprites.ROMInfo{
+			Offset:       int64(game.Offset),
+			Count:        game.Count,
+			PointerBytes: game.PointerBytes,
+			PointerBase:  uint32(game.PointerBase),
+			PointerStyle: game.PointerStyle,
+			Kinds:        kinds,
+		}}
+	}
+
+	setOnCommandLine := map[string]bool{}
+	flag.Visit(func(fl *flag.Flag) {
+		setOnCommandLine[fl.Name] = true
+	})
+
+	for name, v := range cfg.Options {
+		if name == "config" || flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown option: %s", name)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		if err := flag.Set(name, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("%w while setting option %s", err, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/murkland/bnrom/enemies"
+	"github.com/murkland/bnrom/sprites"
+)
+
+// findEnemyTable returns the enemy table the -enemy_table flags give or, without them, the one the config file gives for the game. It's nil if there's neither.
+func findEnemyTable(romID string) *enemies.ROMInfo {
+	if *enemyTableCountF > 0 {
+		return &enemies.ROMInfo{
+			Offset: *enemyTableOffsetF,
+			Count:  *enemyTableCountF,
+			Layout: enemies.Layout{
+				EntrySize:    *enemyEntrySizeF,
+				NameOffset:   *enemyNameFieldF,
+				SpriteOffset: *enemySpriteFieldF,
+			},
+		}
+	}
+	return enemies.FindROMInfo(romID)
+}
+
+// readEnemyLabels reads the enemy table and returns the enemies that use each sprite.
+func readEnemyLabels(r io.ReadSeeker, info enemies.ROMInfo) (map[int][]sprites.AtlasEnemy, error) {
+	entries, err := enemies.ReadTable(r, info)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[int][]sprites.AtlasEnemy{}
+	for spriteIdx, enemyIdxs := range enemies.BySprite(entries) {
+		for _, enemyIdx := range enemyIdxs {
+			labels[spriteIdx] = append(labels[spriteIdx], sprites.AtlasEnemy{Enemy: enemyIdx, NameIndex: entries[enemyIdx].NameIndex})
+		}
+	}
+	return labels, nil
+}
+
+func dumpEnemyLabels(labels map[int][]sprites.AtlasEnemy, outFn string) error {
+	type spriteLabel struct {
+		Sprite  int                  `json:"sprite"`
+		Enemies []sprites.AtlasEnemy `json:"enemies"`
+	}
+
+	out := make([]spriteLabel, 0, len(labels))
+	for spriteIdx, ents := range labels {
+		out = append(out, spriteLabel{spriteIdx, ents})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Sprite < out[j].Sprite
+	})
+
+	return writeFileAtomic(outFn, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	})
+}
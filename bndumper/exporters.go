@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"image/gif"
+	"io"
+	"sort"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+// errNothingToExport is returned by exporters for sprites with nothing to draw, which get no output file.
+var errNothingToExport = errors.New("nothing to draw")
+
+// exporter writes a whole sprite as one file. Name is both what -export selects it by and the output file's extension.
+type exporter interface {
+	Name() string
+	// Export takes the sprite's index as well as its animations, since sheets record it in their metadata.
+	Export(w io.Writer, idx int, anims []sprites.Animation) error
+}
+
+var exporters = map[string]exporter{}
+
+func registerExporter(e exporter) {
+	exporters[e.Name()] = e
+}
+
+// exporterNames returns the names of every registered exporter, sorted, for flag help and errors.
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pngExporter writes the sprite sheet, the same as the default output.
+type pngExporter struct{}
+
+func (pngExporter) Name() string { return "png" }
+
+func (pngExporter) Export(w io.Writer, idx int, anims []sprites.Animation) error {
+	sheet, err := buildSheet(idx, anims, nil)
+	if err != nil {
+		return err
+	}
+	if sheet == nil {
+		return errNothingToExport
+	}
+
+	_, err = sheet.WriteTo(w)
+	return err
+}
+
+// gifExporter writes every animation of the sprite one after another as a single GIF, anchored on their origins like -mode aligned.
+type gifExporter struct{}
+
+func (gifExporter) Name() string { return "gif" }
+
+func (gifExporter) Export(w io.Writer, idx int, anims []sprites.Animation) error {
+	var all sprites.Animation
+	for _, anim := range anims {
+		all.Frames = append(all.Frames, anim.Frames...)
+	}
+
+	g, err := makeAlignedGIF(all)
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return errNothingToExport
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+func init() {
+	registerExporter(pngExporter{})
+	registerExporter(gifExporter{})
+}
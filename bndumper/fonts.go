@@ -82,37 +82,33 @@ func dumpTinyFont(r io.ReadSeeker, outFn string) error {
 		glyphs[i] = glyph
 	}
 
-	outF, err := os.Create(outFn)
-	if err != nil {
-		return err
-	}
-	defer outF.Close()
-
-	p := bdf.Properties{
-		XLFD:      "-murkland-tiny-medium-r-normal--16-160-75-75-c-80-iso10646-1",
-		Size:      16,
-		DPI:       image.Point{75, 75},
-		BPP:       4,
-		BBox:      image.Rect(0, 0, 8, 16),
-		Ascent:    12,
-		Descent:   2,
-		NumGlyphs: len(glyphs),
-	}
-	if err := bdf.WriteProperties(outF, p); err != nil {
-		return fmt.Errorf("%w while writing bdf properties", err)
-	}
-
-	for i, glyph := range glyphs {
-		if err := bdf.WriteGlyph(outF, p, 8, rune(strconv.Itoa(i)[0]), glyph); err != nil {
+	return writeFileAtomic(outFn, func(outF io.Writer) error {
+		p := bdf.Properties{
+			XLFD:      "-murkland-tiny-medium-r-normal--16-160-75-75-c-80-iso10646-1",
+			Size:      16,
+			DPI:       image.Point{75, 75},
+			BPP:       4,
+			BBox:      image.Rect(0, 0, 8, 16),
+			Ascent:    12,
+			Descent:   2,
+			NumGlyphs: len(glyphs),
+		}
+		if err := bdf.WriteProperties(outF, p); err != nil {
 			return fmt.Errorf("%w while writing bdf properties", err)
 		}
-	}
 
-	if err := bdf.WriteTrailer(outF); err != nil {
-		return fmt.Errorf("%w while writing bdf trailer", err)
-	}
+		for i, glyph := range glyphs {
+			if err := bdf.WriteGlyph(outF, p, 8, rune(strconv.Itoa(i)[0]), glyph); err != nil {
+				return fmt.Errorf("%w while writing bdf properties", err)
+			}
+		}
 
-	return nil
+		if err := bdf.WriteTrailer(outF); err != nil {
+			return fmt.Errorf("%w while writing bdf trailer", err)
+		}
+
+		return nil
+	})
 }
 
 func dumpTallFont(r io.ReadSeeker, charmap []rune, outFn string) error {
@@ -125,98 +121,90 @@ func dumpTallFont(r io.ReadSeeker, charmap []rune, outFn string) error {
 		return fmt.Errorf("%w while seeking to tall font pointer", err)
 	}
 
-	outF, err := os.Create(outFn)
-	if err != nil {
-		return err
-	}
-	defer outF.Close()
-
-	p := bdf.Properties{
-		XLFD:      "-murkland-tall-medium-r-normal--16-160-75-75-c-80-iso10646-1",
-		Size:      16,
-		DPI:       image.Point{75, 75},
-		BPP:       4,
-		BBox:      image.Rect(0, 0, 8, 16),
-		Ascent:    12,
-		Descent:   2,
-		NumGlyphs: 448,
-	}
-	if err := bdf.WriteProperties(outF, p); err != nil {
-		return fmt.Errorf("%w while writing bdf properties", err)
-	}
-
-	for i := 0; i < p.NumGlyphs; i++ {
-		glyph, err := fonts.ReadGlyph(r, 1)
-		if err != nil {
-			return fmt.Errorf("%w while reading tall font glyph %d", err, i)
+	return writeFileAtomic(outFn, func(outF io.Writer) error {
+		p := bdf.Properties{
+			XLFD:      "-murkland-tall-medium-r-normal--16-160-75-75-c-80-iso10646-1",
+			Size:      16,
+			DPI:       image.Point{75, 75},
+			BPP:       4,
+			BBox:      image.Rect(0, 0, 8, 16),
+			Ascent:    12,
+			Descent:   2,
+			NumGlyphs: 448,
 		}
-
-		if err := bdf.WriteGlyph(outF, p, 8, charmap[i], glyph); err != nil {
+		if err := bdf.WriteProperties(outF, p); err != nil {
 			return fmt.Errorf("%w while writing bdf properties", err)
 		}
-	}
 
-	if err := bdf.WriteTrailer(outF); err != nil {
-		return fmt.Errorf("%w while writing bdf trailer", err)
-	}
+		for i := 0; i < p.NumGlyphs; i++ {
+			glyph, err := fonts.ReadGlyph(r, 1)
+			if err != nil {
+				return fmt.Errorf("%w while reading tall font glyph %d", err, i)
+			}
 
-	return nil
+			if err := bdf.WriteGlyph(outF, p, 8, charmap[i], glyph); err != nil {
+				return fmt.Errorf("%w while writing bdf properties", err)
+			}
+		}
+
+		if err := bdf.WriteTrailer(outF); err != nil {
+			return fmt.Errorf("%w while writing bdf trailer", err)
+		}
+
+		return nil
+	})
 }
 
 func dumpTall2Font(r io.ReadSeeker, info *fonts.ROMInfo, outFn string) error {
-	outF, err := os.Create(outFn)
-	if err != nil {
-		return err
-	}
-	defer outF.Close()
-
-	p := bdf.Properties{
-		XLFD:      "-murkland-tall2-thin-r-normal--16-160-75-75-c-80-iso10646-1",
-		Size:      16,
-		DPI:       image.Point{75, 75},
-		BPP:       4,
-		BBox:      image.Rect(0, 0, 16, 12),
-		Ascent:    12,
-		Descent:   2,
-		NumGlyphs: 448,
-	}
-	if err := bdf.WriteProperties(outF, p); err != nil {
-		return fmt.Errorf("%w while writing bdf properties", err)
-	}
+	return writeFileAtomic(outFn, func(outF io.Writer) error {
+		p := bdf.Properties{
+			XLFD:      "-murkland-tall2-thin-r-normal--16-160-75-75-c-80-iso10646-1",
+			Size:      16,
+			DPI:       image.Point{75, 75},
+			BPP:       4,
+			BBox:      image.Rect(0, 0, 16, 12),
+			Ascent:    12,
+			Descent:   2,
+			NumGlyphs: 448,
+		}
+		if err := bdf.WriteProperties(outF, p); err != nil {
+			return fmt.Errorf("%w while writing bdf properties", err)
+		}
 
-	if _, err := r.Seek(info.Tall2MetricsOffset, io.SeekStart); err != nil {
-		return fmt.Errorf("%w while seeking to tall font pointer", err)
-	}
+		if _, err := r.Seek(info.Tall2MetricsOffset, io.SeekStart); err != nil {
+			return fmt.Errorf("%w while seeking to tall font pointer", err)
+		}
 
-	metrics, err := fonts.ReadMetrics(r, p.NumGlyphs)
-	if err != nil {
-		return fmt.Errorf("%w while reading metrics properties", err)
-	}
+		metrics, err := fonts.ReadMetrics(r, p.NumGlyphs)
+		if err != nil {
+			return fmt.Errorf("%w while reading metrics properties", err)
+		}
 
-	if _, err := r.Seek(info.Tall2Offset+0x60, io.SeekStart); err != nil {
-		return fmt.Errorf("%w while seeking to tall font pointer", err)
-	}
+		if _, err := r.Seek(info.Tall2Offset+0x60, io.SeekStart); err != nil {
+			return fmt.Errorf("%w while seeking to tall font pointer", err)
+		}
 
-	for i := 0; i < p.NumGlyphs; i++ {
-		var glyph *image.Alpha
-		if i > 0 {
-			var err error
-			glyph, err = fonts.Read16x12Glyph(r)
-			if err != nil {
-				return err
+		for i := 0; i < p.NumGlyphs; i++ {
+			var glyph *image.Alpha
+			if i > 0 {
+				var err error
+				glyph, err = fonts.Read16x12Glyph(r)
+				if err != nil {
+					return err
+				}
+			} else {
+				glyph = image.NewAlpha(p.BBox)
 			}
-		} else {
-			glyph = image.NewAlpha(p.BBox)
-		}
 
-		if err := bdf.WriteGlyph(outF, p, metrics[i], info.Charmap[i], glyph); err != nil {
-			return fmt.Errorf("%w while writing bdf properties", err)
+			if err := bdf.WriteGlyph(outF, p, metrics[i], info.Charmap[i], glyph); err != nil {
+				return fmt.Errorf("%w while writing bdf properties", err)
+			}
 		}
-	}
 
-	if err := bdf.WriteTrailer(outF); err != nil {
-		return fmt.Errorf("%w while writing bdf trailer", err)
-	}
+		if err := bdf.WriteTrailer(outF); err != nil {
+			return fmt.Errorf("%w while writing bdf trailer", err)
+		}
 
-	return nil
+		return nil
+	})
 }
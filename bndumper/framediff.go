@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+var frameDiffHighlight = color.NRGBA{0xff, 0x00, 0xff, 0xff}
+
+func onionSkinFilename(outFn string, idx int, animIdx int) string {
+	return fmt.Sprintf("%s/%04d_%02d_onion.png", outFn, idx, animIdx)
+}
+
+// writeOnionSkins writes an onion skin of the last -onion_frames frames of every animation of a sprite.
+func writeOnionSkins(outFn string, idx int, anims []sprites.Animation) error {
+	for animIdx, anim := range anims {
+		img := sprites.OnionSkin(anim, *onionFramesF)
+		if img.Bounds().Empty() {
+			continue
+		}
+
+		if err := writeFileAtomic(onionSkinFilename(outFn, idx, animIdx), func(w io.Writer) error {
+			return png.Encode(w, img)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func frameDiffFilename(outFn string, idx int, animIdx int) string {
+	return fmt.Sprintf("%s/%04d_%02d_diff.png", outFn, idx, animIdx)
+}
+
+// makeFrameDiffStrip draws an animation's frames side by side, anchored on their origins, with every pixel that changed from the previous frame blended with magenta. The first frame is left as is.
+func makeFrameDiffStrip(anim sprites.Animation) (*image.NRGBA, error) {
+	bounds := anim.UnionBounds()
+	if bounds.Empty() {
+		return nil, nil
+	}
+
+	strip := image.NewNRGBA(image.Rect(0, 0, bounds.Dx()*len(anim.Frames), bounds.Dy()))
+
+	var prev *image.Paletted
+	for i, frame := range anim.Frames {
+		img, err := frame.MakeImage()
+		if err != nil {
+			return nil, fmt.Errorf("%w while rendering frame %d", err, i)
+		}
+
+		src := bounds.Add(frame.CanvasOrigin())
+		cell := image.Rect(i*bounds.Dx(), 0, (i+1)*bounds.Dx(), bounds.Dy())
+		draw.Draw(strip, cell, img, src.Min, draw.Src)
+
+		if prev != nil {
+			for y := 0; y < bounds.Dy(); y++ {
+				for x := 0; x < bounds.Dx(); x++ {
+					p := src.Min.Add(image.Point{x, y})
+					if color.NRGBAModel.Convert(img.At(p.X, p.Y)) == color.NRGBAModel.Convert(prev.At(p.X, p.Y)) {
+						continue
+					}
+
+					c := strip.NRGBAAt(cell.Min.X+x, cell.Min.Y+y)
+					if c.A == 0 {
+						// Pixels that went transparent show as plain highlight.
+						c = frameDiffHighlight
+					} else {
+						c = color.NRGBA{
+							uint8((int(c.R) + int(frameDiffHighlight.R)) / 2),
+							uint8((int(c.G) + int(frameDiffHighlight.G)) / 2),
+							uint8((int(c.B) + int(frameDiffHighlight.B)) / 2),
+							0xff,
+						}
+					}
+					strip.SetNRGBA(cell.Min.X+x, cell.Min.Y+y, c)
+				}
+			}
+		}
+
+		prev = img
+	}
+
+	return strip, nil
+}
+
+// writeFrameDiffs writes a diff strip for every animation of a sprite.
+func writeFrameDiffs(outFn string, idx int, anims []sprites.Animation) error {
+	for animIdx, anim := range anims {
+		strip, err := makeFrameDiffStrip(anim)
+		if err != nil {
+			return fmt.Errorf("%w while diffing animation %d", err, animIdx)
+		}
+
+		if strip == nil {
+			continue
+		}
+
+		if err := writeFileAtomic(frameDiffFilename(outFn, idx, animIdx), func(w io.Writer) error {
+			return png.Encode(w, strip)
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
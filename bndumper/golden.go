@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checkGolden compares every sheet in outFn byte for byte against the sheet of the same name in goldenDir, so rendering changes show up as a failing run. With update set, it replaces the golden sheets with the current ones instead.
+func checkGolden(outFn string, goldenDir string, update bool) error {
+	outFns, err := filepath.Glob(filepath.Join(outFn, "[0-9][0-9][0-9][0-9].png"))
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := os.MkdirAll(goldenDir, 0o700); err != nil {
+			return err
+		}
+
+		for _, fn := range outFns {
+			buf, err := os.ReadFile(fn)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(filepath.Join(goldenDir, filepath.Base(fn)), func(w io.Writer) error {
+				_, err := w.Write(buf)
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+
+		infof("Golden: updated %d sheets in %s", len(outFns), goldenDir)
+		return nil
+	}
+
+	goldenFns, err := filepath.Glob(filepath.Join(goldenDir, "[0-9][0-9][0-9][0-9].png"))
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	mismatched := 0
+
+	for _, fn := range outFns {
+		name := filepath.Base(fn)
+		seen[name] = true
+
+		golden, err := os.ReadFile(filepath.Join(goldenDir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				warnf("Golden: %s has no golden sheet", name)
+				mismatched++
+				continue
+			}
+			return err
+		}
+
+		got, err := os.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(got, golden) {
+			warnf("Golden: %s differs from its golden sheet", name)
+			mismatched++
+		}
+	}
+
+	for _, fn := range goldenFns {
+		if name := filepath.Base(fn); !seen[name] {
+			warnf("Golden: %s was not dumped", name)
+			mismatched++
+		}
+	}
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d sheets don't match %s, rerun with -update_golden if the changes are expected", mismatched, goldenDir)
+	}
+
+	infof("Golden: all %d sheets match", len(outFns))
+	return nil
+}
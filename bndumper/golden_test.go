@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/bnrom/sprites/spritestest"
+)
+
+var updateF = flag.Bool("update", false, "rewrite the golden sheets in testdata instead of comparing against them")
+
+func TestGoldenSheet(t *testing.T) {
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite(
+		[]spritestest.Frame{
+			{Objects: []spritestest.Object{{Fill: 1, X: -8, Y: -16}, {Fill: 2, X: 0, Y: -16}, {Fill: 3, X: -8, Y: -8}}, Delay: 4},
+			{Objects: []spritestest.Object{{Fill: 4, X: -4, Y: -12}}, Delay: 2, Action: uint16(sprites.FrameActionLoop)},
+		},
+		[]spritestest.Frame{
+			{Objects: []spritestest.Object{{Fill: 5, X: 4, Y: 0}, {Fill: 6, X: 12, Y: 0}}, Action: uint16(sprites.FrameActionStop)},
+		},
+	))
+
+	anims, err := sprites.NewReader(bytes.NewReader(rom), sprites.ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	sheet, err := buildSheet(0, anims, nil)
+	if err != nil {
+		t.Fatalf("buildSheet: %s", err)
+	}
+
+	outFn := t.TempDir()
+	if err := writeFileAtomic(spriteFilename(outFn, 0), func(w io.Writer) error {
+		_, err := sheet.WriteTo(w)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkGolden(outFn, filepath.Join("testdata", "golden"), *updateF); err != nil {
+		// In the test, -update does what -update_golden does for the CLI.
+		t.Errorf("checkGolden: %s", err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+// The metadata is inlined into the page rather than fetched from the JSON files, since browsers won't fetch local files from a page opened with file://.
+var htmlIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Sprites</title>
+<style>
+body { font-family: sans-serif; background: #444; color: #eee; }
+.sprite { display: inline-block; vertical-align: top; margin: 8px; padding: 8px; background: #333; }
+canvas { display: block; margin-top: 4px; image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<div id="sprites"></div>
+<script>
+const SHEETS = {{.Sheets}};
+const TICK_MS = {{.TickMS}};
+
+function animFrames(sheet, anim) {
+  return sheet.frames.filter(f => f.anim === anim);
+}
+
+function play(canvas, img, frames) {
+  let left = 0, top = 0, right = 1, bottom = 1;
+  for (const f of frames) {
+    left = Math.min(left, -f.origin_x);
+    top = Math.min(top, -f.origin_y);
+    right = Math.max(right, f.w - f.origin_x);
+    bottom = Math.max(bottom, f.h - f.origin_y);
+  }
+  canvas.width = right - left;
+  canvas.height = bottom - top;
+  canvas.style.width = (canvas.width * 2) + "px";
+  canvas.style.height = (canvas.height * 2) + "px";
+
+  const ctx = canvas.getContext("2d");
+  let i = 0;
+  let timer = null;
+  function step() {
+    const f = frames[i];
+    ctx.clearRect(0, 0, canvas.width, canvas.height);
+    ctx.drawImage(img, f.x, f.y, f.w, f.h, -left - f.origin_x, -top - f.origin_y, f.w, f.h);
+    if (f.action === 2) {
+      return;
+    }
+    i = f.action === 1 || i + 1 >= frames.length ? 0 : i + 1;
+    timer = setTimeout(step, Math.max(f.delay, 1) * TICK_MS);
+  }
+  step();
+  return () => clearTimeout(timer);
+}
+
+const root = document.getElementById("sprites");
+for (const sheet of SHEETS) {
+  const div = document.createElement("div");
+  div.className = "sprite";
+
+  const label = document.createElement("div");
+  label.textContent = String(sheet.sprite).padStart(4, "0") + " ";
+  div.appendChild(label);
+
+  const anims = [...new Set(sheet.frames.map(f => f.anim))];
+  const select = document.createElement("select");
+  for (const anim of anims) {
+    const opt = document.createElement("option");
+    opt.value = anim;
+    opt.textContent = "anim " + anim;
+    select.appendChild(opt);
+  }
+  label.appendChild(select);
+
+  const canvas = document.createElement("canvas");
+  div.appendChild(canvas);
+  root.appendChild(div);
+
+  const img = new Image();
+  let stop = () => {};
+  img.onload = () => {
+    stop = play(canvas, img, animFrames(sheet, anims[0]));
+  };
+  select.onchange = () => {
+    stop();
+    stop = play(canvas, img, animFrames(sheet, Number(select.value)));
+  };
+  img.src = sheet.image;
+}
+</script>
+</body>
+</html>
+`))
+
+// writeHTMLIndex writes an index.html that plays every sheet in outFn that has JSON metadata, including ones left over from earlier runs.
+func writeHTMLIndex(outFn string) error {
+	fns, err := filepath.Glob(outFn + "/[0-9][0-9][0-9][0-9].json")
+	if err != nil {
+		return err
+	}
+
+	sheets := make([]*sprites.AtlasMetadata, 0, len(fns))
+	for _, fn := range fns {
+		meta, err := readSheetJSON(fn)
+		if err != nil {
+			return fmt.Errorf("%w while reading %s", err, fn)
+		}
+		sheets = append(sheets, meta)
+	}
+
+	return writeFileAtomic(outFn+"/index.html", func(w io.Writer) error {
+		return htmlIndexTemplate.Execute(w, struct {
+			Sheets []*sprites.AtlasMetadata
+			TickMS float64
+		}{sheets, float64(sprites.TicksToDuration(1)) / float64(time.Millisecond)})
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+func sheetJSONFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d.json", outFn, idx)
+}
+
+// writeSheetJSON writes the same frame metadata as the fctrl chunk, as a JSON file next to the sheet for tools that can't read PNG chunks.
+func writeSheetJSON(outFn string, idx int, kind sprites.Kind, enemies []sprites.AtlasEnemy, sheetSize image.Point, infos []frameInfo, grid *sprites.AtlasGrid) error {
+	meta := sprites.AtlasMetadata{
+		Sprite:     idx,
+		Image:      filepath.Base(spriteFilename(outFn, idx)),
+		Width:      sheetSize.X,
+		Height:     sheetSize.Y,
+		Frames:     make([]sprites.AtlasFrame, len(infos)),
+		Animations: animRanges(infos),
+		Grid:       grid,
+		Enemies:    enemies,
+	}
+	if kind != sprites.UnknownKind {
+		meta.Kind = kind.String()
+	}
+	// The schema doesn't allow null, even for a sheet with no frames.
+	if meta.Animations == nil {
+		meta.Animations = []sprites.AtlasAnimation{}
+	}
+
+	for i, info := range infos {
+		meta.Frames[i] = sprites.AtlasFrame{
+			Anim:    info.Anim,
+			X:       info.BBox.Min.X,
+			Y:       info.BBox.Min.Y,
+			W:       info.BBox.Dx(),
+			H:       info.BBox.Dy(),
+			OriginX: info.Origin.X,
+			OriginY: info.Origin.Y,
+			Delay:   info.Delay,
+			Action:  int(fctrlAction(info.Action)),
+			Event:   int(info.Event),
+		}
+	}
+
+	return writeFileAtomic(sheetJSONFilename(outFn, idx), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(meta)
+	})
+}
+
+func readSheetJSON(fn string) (*sprites.AtlasMetadata, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var meta sprites.AtlasMetadata
+	if err := json.NewDecoder(f).Decode(&meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// minLogLevel is set from -v and -q. Errors are always logged.
+var minLogLevel = levelInfo
+
+func logAt(level logLevel, format string, args ...interface{}) {
+	if level < minLogLevel {
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func debugf(format string, args ...interface{}) {
+	logAt(levelDebug, format, args...)
+}
+
+func infof(format string, args ...interface{}) {
+	logAt(levelInfo, format, args...)
+}
+
+func warnf(format string, args ...interface{}) {
+	logAt(levelWarn, format, args...)
+}
+
+// errorf logs an error, whatever -q says, and exits with status 1.
+func errorf(format string, args ...interface{}) {
+	logAt(levelError, format, args...)
+	os.Exit(1)
+}
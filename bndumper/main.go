@@ -1,20 +1,88 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"fmt"
+	"image/color"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/murkland/bnrom/enemies"
+	"github.com/murkland/bnrom/sprites"
 	"github.com/murkland/gbarom"
 )
 
 var (
-	dumpSpritesF     = flag.Bool("dump_sprites", true, "dump sprites")
-	dumpBattletilesF = flag.Bool("dump_battletiles", true, "dump battletiles")
-	dumpChipsF       = flag.Bool("dump_chips", true, "dump chips")
-	dumpFontsF       = flag.Bool("dump_fonts", true, "dump fonts")
+	dumpSpritesF      = flag.Bool("dump_sprites", true, "dump sprites")
+	dumpBattletilesF  = flag.Bool("dump_battletiles", true, "dump battletiles")
+	dumpChipsF        = flag.Bool("dump_chips", true, "dump chips")
+	dumpFontsF        = flag.Bool("dump_fonts", true, "dump fonts")
+	dumpPalettesF     = flag.Bool("dump_palettes", false, "dump sprite palettes as .act, .pal and Lospec .hex files")
+	paletteSwatchesF  = flag.Bool("palette_swatches", false, "with -dump_palettes, also draw each sprite's palettes as labelled swatches")
+	backgroundF       = flag.String("background", "", "dump a background to background.png, given as tileset,tilemap,palette,width,height: hex offsets of the three ROM pointers, then the size in tiles")
+	textMetaF         = flag.Bool("text_meta", false, "also write a human-readable tEXt chunk with sprite metadata")
+	powerOfTwoF       = flag.Bool("power_of_two", false, "pad sprite sheets to power-of-two dimensions")
+	enemyTableOffsetF = flag.Int64("enemy_table_offset", 0, "offset of the enemy table, used to label sprites by enemy")
+	enemyTableCountF  = flag.Int("enemy_table_count", 0, "number of entries in the enemy table")
+	enemyEntrySizeF   = flag.Int64("enemy_entry_size", enemies.PackedLayout.EntrySize, "size of each enemy table entry in bytes")
+	enemyNameFieldF   = flag.Int64("enemy_name_field", enemies.PackedLayout.NameOffset, "offset of the uint16 name index in each enemy table entry")
+	enemySpriteFieldF = flag.Int64("enemy_sprite_field", enemies.PackedLayout.SpriteOffset, "offset of the uint16 sprite index in each enemy table entry")
+	skipExistingF     = flag.Bool("skip_existing", false, "skip sprites whose output already exists and matches the manifest")
+	skipEmptyF        = flag.Bool("skip_empty", false, "skip sprite slots with a null pointer or with nothing to draw")
+	megaF             = flag.Bool("mega", false, "pack every sprite into shared mega atlas pages instead of one sheet per sprite")
+	megaColorsF       = flag.Int("mega_colors", 0, "quantize mega atlas pages to at most this many colors (up to 256) instead of writing them as RGBA")
+	ditherF           = flag.Bool("dither", false, "dither when quantizing mega atlas pages")
+	exportF           = flag.String("export", "png", "format of each sprite's main output, <sprite>.<format>: png for the sprite sheet, gif for every animation in one GIF anchored on their origins, or mp4 or webm for the same as a video at the GBA's frame rate, which needs ffmpeg")
+	formatF           = flag.String("format", "png", "sprite sheet format: png, tiled to also write a Tiled tileset, json to also write JSON metadata, or spine to also write a Spine skeleton and texture atlas")
+	modeF             = flag.String("mode", "", "html to also write JSON metadata and an index.html that plays every dumped sprite, layered to also write every frame as an OpenRaster file with one layer per OAM object, aligned to also write every animation as a GIF with its frames anchored on their origins, diff to also write every animation as a strip highlighting the pixels that changed from the previous frame, onion to also write the last -onion_frames frames of every animation stacked with fading opacity, or timing-csv to only write timing.csv with every frame's delay, action and cumulative ticks within its animation, without rendering anything")
+	onionFramesF      = flag.Int("onion_frames", 4, "with -mode onion, how many frames to stack, or 0 for all of them")
+	diffF             = flag.String("diff", "", "only dump sprites that differ from the ones in this ROM")
+	noTrimF           = flag.Bool("no_trim", false, "pack every frame into sprite sheets on its whole 512x512 canvas instead of trimming it, so positions within frames are absolute")
+	cropF             = flag.String("crop", "", "only keep the pixels of each frame inside x,y,w,h, relative to its origin, before trimming and packing, e.g. -16,-32,32,32 to isolate an object that always sits in the same place")
+	trimMinAlphaF     = flag.Int("trim_min_alpha", 1, "minimum alpha for a pixel to be kept when trimming frames")
+	paddingF          = flag.Int("padding", 0, "transparent pixels to add around every frame in sprite sheets")
+	progressF         = flag.String("progress", "bar", "progress output on stderr: bar, none, or jsonl for one JSON object per sprite")
+	alignF            = flag.Int("align", 1, "snap the top-left corner of every frame in sprite sheets to a multiple of this many pixels")
+	animNamesF        = flag.String("anim_names", "", "CSV file of sprite,animation,name rows naming animations in sprite metadata and aligned GIF file names")
+	dumpRawF          = flag.String("dump_raw", "", "also write the bytes each sprite is parsed from, after LZ77 decompression, to <sprite>.bin files in this directory")
+	gridF             = flag.Bool("grid", false, "lay sprite sheets out one animation per row in equal cells, with every frame's origin at the same point in its cell, instead of packing them; ignores -padding, -align and -power_of_two")
+	configF           = flag.String("config", "", "JSON file adding games to the sprite table registry and setting options by flag name")
+	pngTypeF          = flag.String("pngtype", "indexed", "PNG color type for sprite sheets: indexed, rgba, or gray, which fails for sprites with non-gray colors and drops transparency")
+	remapPaletteF     = flag.String("remap_palette", "", "remap sprite sheets onto the nearest colors of this Lospec .hex or .json palette, keeping transparency")
+	colorKeyF         = flag.String("color_key", "", "replace transparency in sprite sheets with this RRGGBB color, e.g. ff00ff, failing for sprites that already use it")
+	verboseF          = flag.Bool("v", false, "also log debug messages, such as what each sprite decoded to")
+	quietF            = flag.Bool("q", false, "only log warnings and errors")
+	debugOverlayF     = flag.Bool("debug_overlay", false, "also write a copy of each sprite sheet with every frame's box, index and origin drawn on, as <sprite>_debug.png")
+	countCheckF       = flag.Bool("count_check", false, "also write a copy of each sprite sheet with the sprite index and its total frame count drawn in a strip underneath, as <sprite>_count.png, to spot sprites that came out short when reviewing in bulk")
+	brightnessF       = flag.Float64("brightness", 1, "multiply every color channel by this after BGR555 decoding, to match a screen or emulator")
+	gammaF            = flag.Float64("gamma", 1, "raise every color channel, from 0 to 1, to the power of 1/gamma after BGR555 decoding, so values over 1 lighten midtones")
+	srgbF             = flag.Bool("srgb", false, "mark sprite sheets as sRGB with sRGB, gAMA and cHRM chunks, for color-managed viewers")
+	goldenF           = flag.String("golden", "", "compare the dumped sprite sheets against the ones in this directory and fail if any differ")
+	updateGoldenF     = flag.Bool("update_golden", false, "with -golden, replace the sheets in the golden directory instead of comparing against them")
+	strictF           = flag.Bool("strict", false, "skip sprites whose data sizes don't add up, even if they'd otherwise decode")
+	maxAnimFramesF    = flag.Int("max_anim_frames", sprites.DefaultMaxAnimationFrames, "skip sprites with animations longer than this many frames, which usually means misaligned data")
+	maxFrameDimF      = flag.Int("max_frame_dim", sprites.DefaultMaxFrameDim, "skip sprites with frames wider or taller than this, which usually means a bad table offset")
+	listGamesF        = flag.Bool("list_games", false, "list supported games and exit")
+	findTableF        = flag.Bool("find_table", false, "guess where each ROM's sprite table is, print the offset and pointer style and exit, for adding a game with -config; it's a heuristic, so confirm the offset with -check")
+	spriteF           = flag.Int("sprite", -1, "only dump this sprite")
+	animF             = flag.Int("anim", -1, "with -sprite, only dump this animation of it")
+	validateF         = flag.Bool("validate", false, "check that every packed frame lines up with its origin (slow, for debugging)")
+	strictOriginF     = flag.Bool("strict_origin", false, "with -validate, fail frames whose origin is outside their box instead of warning")
+	globalPaletteF    = flag.Bool("global_palette", false, "remap every dumped sprite sheet into one shared palette of at most 256 colors")
+	masterPaletteF    = flag.Bool("master_palette", false, "like -global_palette, but quantize to 256 colors if the sprites use more, and write the palette to master.pal, .act and .hex and each sprite's color error to master.csv")
+	validateOutputF   = flag.Bool("validate_output", false, "after writing each sprite sheet, read it back and check that it decodes to the right size and that its fctrl and fanim chunks parse back to what was written; sheets that don't are reported and the run fails at the end")
+	checkF            = flag.Bool("check", false, "decode and render every sprite without writing anything, and exit nonzero if any fail")
+	stdoutF           = flag.Bool("stdout", false, "write the sheet for the sprite selected with -sprite to stdout and dump nothing else")
 )
 
+// fctrlVersion is written after the fctrl keyword, ahead of the frames. Version 1 had no version byte and a byte each for Delay and Action, as 0 for next, 1 for loop and 2 for stop; its chunks are always an even number of bytes long, and version 2's odd, so readers can tell them apart.
+const fctrlVersion = 2
+
 type fctrlFrameInfo struct {
 	Left    int16
 	Top     int16
@@ -22,53 +90,431 @@ type fctrlFrameInfo struct {
 	Bottom  int16
 	OriginX int16
 	OriginY int16
-	Delay   uint8
-	Action  uint8
+	// Delay and Action are as wide as sprites.Frame's, so neither is ever truncated. Action is the frame's whole sprites.FrameAction, event bits included.
+	Delay  uint16
+	Action sprites.FrameAction
 }
 
-func main() {
-	flag.Parse()
+const fanimVersion = 1
+
+// fanimRange is followed by NameLen bytes of name.
+type fanimRange struct {
+	Start   uint16
+	Count   uint16
+	NameLen uint8
+}
+
+func listGames() {
+	codes := make([]string, 0, len(sprites.KnownGames))
+	for code := range sprites.KnownGames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		gi := sprites.KnownGames[code]
+		fmt.Printf("%s\t%s\t0x%08x\t%d\n", code, gi.Title, gi.Offset, gi.Count)
+	}
+}
+
+func findSpriteTable(fn string) (int64, sprites.PointerStyle, error) {
+	f, err := openROM(fn)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	off, err := sprites.FindSpriteTable(data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The start of the table is enough to tell the styles apart, and going further could count whatever comes after it.
+	count := int((int64(len(data)) - off) / 4)
+	if count > 64 {
+		count = 64
+	}
+	style, err := sprites.DetectPointerStyle(data, sprites.ROMInfo{Offset: off, Count: count})
+	if err != nil {
+		return 0, 0, err
+	}
+	return off, style, nil
+}
 
-	f, err := os.Open(flag.Arg(0))
+// dumpROM dumps everything asked for from one ROM, into the current directory or, in batch mode, into out/<rom id>.
+func dumpROM(ctx context.Context, fn string, batch bool) error {
+	f, err := openROM(fn)
 	if err != nil {
-		log.Fatalf("%s", err)
+		return err
 	}
 	defer f.Close()
 
 	romTitle, err := gbarom.ReadROMTitle(f)
 	if err != nil {
-		log.Fatalf("%s", err)
+		return err
 	}
 
-	log.Printf("Game title: %s", romTitle)
+	infof("Game title: %s", romTitle)
+
+	outDir := "."
+	if batch {
+		romID, err := gbarom.ReadROMID(f)
+		if err != nil {
+			return err
+		}
+
+		outDir = filepath.Join("out", romID)
+		if err := os.MkdirAll(outDir, 0o700); err != nil {
+			return err
+		}
+	}
 
 	if *dumpSpritesF {
-		log.Printf("Dumping sprites...")
-		if err := dumpSprites(f, "sprites"); err != nil {
-			log.Fatalf("%s", err)
+		infof("Dumping sprites...")
+		if err := dumpSprites(ctx, f, filepath.Join(outDir, "sprites")); err != nil {
+			return err
+		}
+
+		if *goldenF != "" {
+			if err := checkGolden(filepath.Join(outDir, "sprites"), *goldenF, *updateGoldenF); err != nil {
+				return err
+			}
 		}
 	}
 
+	if *dumpRawF != "" {
+		infof("Dumping raw sprites...")
+		if err := dumpRawSprites(ctx, f, filepath.Join(outDir, *dumpRawF)); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if *dumpBattletilesF {
-		log.Printf("Dumping battletiles...")
-		if err := dumpBattletiles(f, "battletiles.png"); err != nil {
-			log.Fatalf("%s", err)
+		infof("Dumping battletiles...")
+		if err := dumpBattletiles(f, filepath.Join(outDir, "battletiles.png")); err != nil {
+			return err
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if *dumpChipsF {
-		log.Printf("Dumping chips...")
-		if err := dumpChips(f, "chips.png", "chipicons.png"); err != nil {
-			log.Fatalf("%s", err)
+		infof("Dumping chips...")
+		if err := dumpChips(f, filepath.Join(outDir, "chips.png"), filepath.Join(outDir, "chipicons.png")); err != nil {
+			return err
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if *dumpFontsF {
-		log.Printf("Dumping fonts...")
-		if err := dumpFonts(f, "fonts"); err != nil {
-			log.Fatalf("%s", err)
+		infof("Dumping fonts...")
+		if err := dumpFonts(f, filepath.Join(outDir, "fonts")); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if *dumpPalettesF {
+		infof("Dumping palettes...")
+		if err := dumpPalettes(ctx, f, filepath.Join(outDir, "palettes")); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if *backgroundF != "" {
+		infof("Dumping background...")
+		ri, err := parseBackgroundInfo(*backgroundF)
+		if err != nil {
+			return err
+		}
+		if err := dumpBackground(f, ri, filepath.Join(outDir, "background.png")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *verboseF && *quietF {
+		errorf("-v and -q can't be used together")
+	}
+	if *verboseF {
+		minLogLevel = levelDebug
+	}
+	if *quietF {
+		minLogLevel = levelWarn
+	}
+
+	if *configF != "" {
+		if err := loadConfig(*configF); err != nil {
+			errorf("%s while reading %s", err, *configF)
+		}
+	}
+
+	if *listGamesF {
+		listGames()
+		return
+	}
+
+	switch *formatF {
+	case "png", "tiled", "json", "spine":
+	default:
+		errorf("unknown format: %s", *formatF)
+	}
+
+	if _, ok := exporters[*exportF]; !ok {
+		errorf("unknown export format: %s, expected one of %s", *exportF, strings.Join(exporterNames(), ", "))
+	}
+	if *exportF != "png" && (*formatF != "png" || *megaF || *goldenF != "") {
+		errorf("-export %s doesn't support -format, -mega or -golden, which need sprite sheets", *exportF)
+	}
+	if _, ok := exporters[*exportF].(videoExporter); ok {
+		if _, err := findFFmpeg(); err != nil {
+			errorf("-export %s: %s", *exportF, err)
+		}
+	}
+
+	if *maxAnimFramesF < 1 {
+		errorf("-max_anim_frames must be at least 1")
+	}
+	if *maxFrameDimF < 8 {
+		errorf("-max_frame_dim must be at least 8, the size of a tile")
+	}
+	decodeOptions = sprites.DecodeOptions{Strict: *strictF, MaxAnimationFrames: *maxAnimFramesF, MaxFrameDim: *maxFrameDimF}
+
+	if *brightnessF < 0 {
+		errorf("-brightness can't be negative")
+	}
+	if *gammaF <= 0 {
+		errorf("-gamma must be positive")
+	}
+	colorAdjust = sprites.ColorAdjust{Brightness: *brightnessF, Gamma: *gammaF}
+
+	if *animNamesF != "" {
+		var err error
+		animNames, err = readAnimNames(*animNamesF)
+		if err != nil {
+			errorf("%s while reading %s", err, *animNamesF)
+		}
+	}
+
+	if *megaColorsF < 0 || *megaColorsF > 256 {
+		errorf("-mega_colors must be between 0 and 256")
+	}
+
+	// The threshold is compared against 8-bit alpha, so anything else would wrap around.
+	if *trimMinAlphaF < 0 || *trimMinAlphaF > 255 {
+		errorf("-trim_min_alpha must be between 0 and 255")
+	}
+
+	// Negative padding would pack frames over each other, at negative coordinates.
+	if *paddingF < 0 {
+		errorf("-padding can't be negative")
+	}
+
+	if *alignF < 0 {
+		errorf("-align can't be negative")
+	}
+
+	switch *progressF {
+	case "bar", "none", "jsonl":
+	default:
+		errorf("unknown progress output: %s", *progressF)
+	}
+
+	if *remapPaletteF != "" {
+		p, err := readLospecFile(*remapPaletteF)
+		if err != nil {
+			errorf("%s while reading %s", err, *remapPaletteF)
+		}
+		if len(p) == 0 || len(p) > 255 {
+			errorf("-remap_palette needs between 1 and 255 colors, %s has %d", *remapPaletteF, len(p))
+		}
+		remapPalette = append(color.Palette{color.NRGBA{}}, p...)
+	}
+
+	if *cropF != "" {
+		r, err := parseCrop(*cropF)
+		if err != nil {
+			errorf("%s while parsing -crop", err)
+		}
+		crop = &r
+	}
+
+	if *colorKeyF != "" {
+		key, err := parseColorKey(*colorKeyF)
+		if err != nil {
+			errorf("%s while parsing -color_key", err)
+		}
+		colorKey = &key
+	}
+
+	switch *pngTypeF {
+	case "indexed", "rgba", "gray":
+	default:
+		errorf("unknown png type: %s", *pngTypeF)
+	}
+
+	switch *modeF {
+	case "", "html", "layered", "aligned", "diff", "onion", "timing-csv":
+	default:
+		errorf("unknown mode: %s", *modeF)
+	}
+
+	if *masterPaletteF && (*globalPaletteF || *megaF || *stdoutF || *exportF != "png") {
+		errorf("-master_palette only supports -export png, without -global_palette, -mega or -stdout")
+	}
+
+	if *validateOutputF && (*megaF || *stdoutF || *exportF != "png") {
+		errorf("-validate_output only checks sprite sheets, so it only supports -export png, without -mega or -stdout")
+	}
+
+	if *gridF && *megaF {
+		errorf("-grid doesn't support -mega")
+	}
+
+	if *animF >= 0 && *spriteF < 0 {
+		errorf("-anim requires -sprite")
+	}
+
+	if *checkF && *stdoutF {
+		errorf("-check and -stdout can't be used together")
+	}
+
+	if *stdoutF {
+		if *spriteF < 0 {
+			errorf("-stdout requires -sprite")
+		}
+		if *megaF || *formatF != "png" {
+			errorf("-stdout only supports -format png without -mega")
+		}
+		if *exportF != "png" && *globalPaletteF {
+			errorf("-stdout only supports -global_palette with -export png")
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		// Let a second Ctrl-C kill the process outright.
+		<-ctx.Done()
+		stop()
+	}()
+
+	if flag.NArg() == 0 {
+		errorf("usage: bndumper [flags] rom.gba|rom.zip|rom.gba.gz...")
+	}
+
+	if *stdoutF {
+		if flag.NArg() != 1 {
+			errorf("-stdout only supports a single ROM")
+		}
+
+		f, err := openROM(flag.Arg(0))
+		if err != nil {
+			errorf("%s", err)
+		}
+		defer f.Close()
+
+		if err := dumpSpriteToStdout(f); err != nil {
+			errorf("%s", err)
+		}
+		return
+	}
+
+	if *findTableF {
+		for _, fn := range flag.Args() {
+			off, style, err := findSpriteTable(fn)
+			if err != nil {
+				errorf("%s: %s", fn, err)
+			}
+			fmt.Printf("%s\t0x%08x\t%s\n", fn, off, style)
+		}
+		return
+	}
+
+	if *checkF {
+		failed := 0
+		for _, fn := range flag.Args() {
+			f, err := openROM(fn)
+			if err != nil {
+				errorf("%s", err)
+			}
+
+			n, err := checkSprites(ctx, f)
+			f.Close()
+			if err != nil {
+				errorf("%s: %s", fn, err)
+			}
+			failed += n
+		}
+
+		if failed > 0 {
+			errorf("check failed: %d sprites failed", failed)
+		}
+		infof("Done!")
+		return
+	}
+
+	if flag.NArg() == 1 {
+		if err := dumpROM(ctx, flag.Arg(0), false); err != nil {
+			errorf("%s", err)
 		}
+		infof("Done!")
+		return
+	}
+
+	// Batch mode: dump each ROM into out/<rom id>, carrying on past ROMs that fail.
+	results := make([]string, flag.NArg())
+	failed := 0
+	for i, fn := range flag.Args() {
+		if ctx.Err() != nil {
+			results[i] = "not started"
+			failed++
+			continue
+		}
+
+		infof("ROM %d of %d: %s", i+1, flag.NArg(), fn)
+		if err := dumpROM(ctx, fn, true); err != nil {
+			warnf("%s: %s", fn, err)
+			results[i] = fmt.Sprintf("failed: %s", err)
+			failed++
+			continue
+		}
+		results[i] = "ok"
+	}
+
+	for i, fn := range flag.Args() {
+		infof("%s: %s", fn, results[i])
+	}
+
+	if failed > 0 {
+		errorf("%d of %d ROMs failed", failed, flag.NArg())
 	}
 
-	log.Printf("Done!")
+	infof("Done!")
 }
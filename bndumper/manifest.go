@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+// manifestVersion is bumped whenever what the manifest records changes, so manifests from older versions are thrown away rather than misread.
+const manifestVersion = 2
+
+// manifestEntry records what a sprite's output was made from as well as what it was, so -skip_existing can tell both a changed sprite and an edited output apart from an up to date one.
+type manifestEntry struct {
+	// Source is the sprites.Hash of the sprite's animations, as they were when the output was written.
+	Source string `json:"source"`
+	// Output is the SHA-256 of the output file.
+	Output string `json:"output"`
+}
+
+type manifest struct {
+	Version int    `json:"version"`
+	ROMID   string `json:"rom_id"`
+	// Options is optionsDigest of the run that wrote the outputs.
+	Options string                `json:"options"`
+	Sprites map[int]manifestEntry `json:"sprites"`
+}
+
+// optionsIgnored lists the flags that can't change what a sprite's output looks like: they pick what gets dumped, or how the run reports.
+var optionsIgnored = map[string]bool{
+	"dump_sprites":     true,
+	"dump_battletiles": true,
+	"dump_chips":       true,
+	"dump_fonts":       true,
+	"dump_palettes":    true,
+	"skip_existing":    true,
+	"progress":         true,
+	"config":           true,
+	"v":                true,
+	"q":                true,
+	"list_games":       true,
+	"find_table":       true,
+	"sprite":           true,
+	"diff":             true,
+	"validate":         true,
+	"strict_origin":    true,
+	"validate_output":  true,
+	"check":            true,
+	"stdout":           true,
+	"golden":           true,
+	"update_golden":    true,
+}
+
+// optionsDigest hashes the value of every flag that might affect output, after -config has set its options. Flags are in unless they're in optionsIgnored, so a new flag can only cause an unneeded rebuild, never a stale output.
+func optionsDigest() string {
+	h := sha256.New()
+	flag.VisitAll(func(fl *flag.Flag) {
+		if optionsIgnored[fl.Name] {
+			return
+		}
+		fmt.Fprintf(h, "%s=%q\n", fl.Name, fl.Value.String())
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sourceHash(anims []sprites.Animation) string {
+	h := sprites.Hash(anims)
+	return hex.EncodeToString(h[:])
+}
+
+// readManifest reads the manifest at fn. A missing manifest, or one from another manifest version, reads as an empty one.
+func readManifest(fn string) (*manifest, error) {
+	m := &manifest{Version: manifestVersion, Sprites: map[int]manifestEntry{}}
+
+	f, err := os.Open(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var version struct {
+		Version int `json:"version"`
+	}
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &version); err != nil {
+		return nil, err
+	}
+	if version.Version != manifestVersion {
+		return m, nil
+	}
+
+	if err := json.Unmarshal(buf, m); err != nil {
+		return nil, err
+	}
+
+	if m.Sprites == nil {
+		m.Sprites = map[int]manifestEntry{}
+	}
+
+	return m, nil
+}
+
+func writeManifest(fn string, m *manifest) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	})
+}
+
+func hashFile(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// upToDate reports whether fn was written from a sprite with the source hash source, and still has the hash recorded for idx. The options are checked for the whole manifest when it's read, in dumpSprites.
+func (m *manifest) upToDate(idx int, fn string, source string) bool {
+	expected, ok := m.Sprites[idx]
+	if !ok || expected.Source != source {
+		return false
+	}
+
+	actual, err := hashFile(fn)
+	if err != nil {
+		return false
+	}
+
+	return actual == expected.Output
+}
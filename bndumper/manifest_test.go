@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestUpToDate(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "0000.png")
+	if err := os.WriteFile(fn, []byte("sheet"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := hashFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &manifest{Sprites: map[int]manifestEntry{0: {Source: "a", Output: out}}}
+	if !m.upToDate(0, fn, "a") {
+		t.Errorf("unchanged sprite and output aren't up to date")
+	}
+	if m.upToDate(0, fn, "b") {
+		t.Errorf("changed sprite is up to date")
+	}
+	if m.upToDate(1, fn, "a") {
+		t.Errorf("sprite missing from the manifest is up to date")
+	}
+
+	if err := os.WriteFile(fn, []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if m.upToDate(0, fn, "a") {
+		t.Errorf("edited output is up to date")
+	}
+}
+
+func TestOptionsDigest(t *testing.T) {
+	defer func(padding int, progress string) {
+		*paddingF = padding
+		flag.Set("progress", progress)
+	}(*paddingF, flag.Lookup("progress").Value.String())
+
+	before := optionsDigest()
+
+	flag.Set("progress", "none")
+	if optionsDigest() != before {
+		t.Errorf("-progress changed the options digest")
+	}
+
+	*paddingF++
+	if optionsDigest() == before {
+		t.Errorf("-padding didn't change the options digest")
+	}
+}
+
+func TestReadManifestOldVersion(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(fn, []byte(`{"rom_id": "BR6E", "sprites": {"0": "abcd"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := readManifest(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ROMID != "" || len(m.Sprites) != 0 {
+		t.Errorf("old manifest read as %+v, want an empty one", m)
+	}
+}
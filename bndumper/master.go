@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/palettes"
+	"github.com/murkland/bnrom/sprites"
+)
+
+// masterPaletteError returns the root mean square color error of drawing a sprite in p instead of its own palettes, over the visible pixels of all its frames.
+func masterPaletteError(anims []sprites.Animation, p color.Palette) (float64, error) {
+	var sum float64
+	pixels := 0
+	for _, anim := range anims {
+		for _, frame := range anim.Frames {
+			if len(frame.OAMEntries) == 0 {
+				continue
+			}
+
+			own, err := frame.MakeImage()
+			if err != nil {
+				return 0, err
+			}
+
+			trim := paletted.FindTrimThreshold(own, uint8(*trimMinAlphaF))
+			if trim.Empty() {
+				continue
+			}
+
+			remapped, err := frame.MakeImageWithPalette(p)
+			if err != nil {
+				return 0, err
+			}
+
+			e := sprites.ColorError(own.SubImage(trim), remapped.SubImage(trim))
+			area := trim.Dx() * trim.Dy()
+			sum += e * e * float64(area)
+			pixels += area
+		}
+	}
+
+	if pixels == 0 {
+		return 0, nil
+	}
+	return math.Sqrt(sum / float64(pixels)), nil
+}
+
+// writeMasterPalette writes the -master_palette palette as master.pal, .act and .hex, and master.csv with every sprite's color error in it.
+func writeMasterPalette(ctx context.Context, outFn string, s []work, p color.Palette) error {
+	for _, ext := range []struct {
+		name  string
+		write func(io.Writer, color.Palette) error
+	}{
+		{".pal", palettes.WriteJASC},
+		{".act", palettes.WriteACT},
+		{".hex", sprites.WriteLospec},
+	} {
+		if err := writeFileAtomic(outFn+"/master"+ext.name, func(w io.Writer) error {
+			return ext.write(w, p)
+		}); err != nil {
+			return err
+		}
+	}
+
+	bar := newProgress("master palette", len(s))
+
+	worst, worstIdx := 0.0, -1
+	if err := writeFileAtomic(outFn+"/master.csv", func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"sprite", "error"}); err != nil {
+			return err
+		}
+
+		for _, wk := range s {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			bar.step(wk.idx)
+
+			e, err := masterPaletteError(wk.anims, p)
+			if err != nil {
+				if !sprites.IsDecodeError(err) {
+					return err
+				}
+				// The dump that follows warns about this sprite too.
+				bar.report(wk.idx, "failed", err)
+				continue
+			}
+			bar.report(wk.idx, "measured", nil)
+
+			if e > 0 {
+				debugf("%04d: master palette color error %.2f", wk.idx, e)
+			}
+			if e > worst {
+				worst, worstIdx = e, wk.idx
+			}
+
+			if err := cw.Write([]string{strconv.Itoa(wk.idx), strconv.FormatFloat(e, 'f', 4, 64)}); err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+	}); err != nil {
+		return err
+	}
+
+	if worstIdx < 0 {
+		infof("Master palette: %d colors, every sprite exact", len(p))
+	} else {
+		infof("Master palette: %d colors, worst color error %.2f in sprite %04d", len(p), worst, worstIdx)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/murkland/bnrom/atlas"
+	"github.com/murkland/bnrom/sprites"
+)
+
+const megaPageSize = 2048
+
+type megaFrameInfo struct {
+	Sprite  int `json:"sprite"`
+	Anim    int `json:"anim"`
+	Frame   int `json:"frame"`
+	Page    int `json:"page"`
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	W       int `json:"w"`
+	H       int `json:"h"`
+	OriginX int `json:"origin_x"`
+	OriginY int `json:"origin_y"`
+	Delay   int `json:"delay"`
+	Action  int `json:"action"`
+
+	AnimName string `json:"anim_name,omitempty"`
+}
+
+func megaPageFilename(outFn string, page int) string {
+	return fmt.Sprintf("%s/mega_%02d.png", outFn, page)
+}
+
+func writeMegaPage(fn string, img *image.RGBA, size image.Point) error {
+	page := img.SubImage(image.Rectangle{Max: size})
+	if *megaColorsF <= 0 {
+		return writeFileAtomic(fn, func(w io.Writer) error {
+			return png.Encode(w, page)
+		})
+	}
+
+	var quantized *image.Paletted
+	if *ditherF {
+		quantized = sprites.QuantizeDithered(page, *megaColorsF)
+	} else {
+		quantized = sprites.Quantize(page, *megaColorsF)
+	}
+	infof("%s: quantized to %d colors, rms error %.2f", fn, len(quantized.Palette), sprites.ColorError(page, quantized))
+
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		return png.Encode(w, quantized)
+	})
+}
+
+// dumpMegaAtlas packs the frames of every sprite into shared RGBA pages. Sprites don't share palettes, so unlike the per-sprite sheets the pages aren't paletted.
+func dumpMegaAtlas(ctx context.Context, s []work, outFn string) error {
+	opts := atlas.PackOptions{
+		Padding: *paddingF,
+		Align:   *alignF,
+	}
+
+	page := 0
+	pageImg := image.NewRGBA(image.Rect(0, 0, megaPageSize, megaPageSize))
+	packer := atlas.NewPacker(megaPageSize, megaPageSize, opts)
+
+	var infos []megaFrameInfo
+
+	bar := newProgress("mega", len(s))
+	for _, w := range s {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w while packing mega atlas", err)
+		}
+
+		bar.step(w.idx)
+
+		for animIdx, anim := range w.anims {
+			for frameIdx, frame := range anim.Frames {
+				trimmed, origin, err := renderTrimmedFrame(frame, nil)
+				if err != nil {
+					return fmt.Errorf("%w while rendering sprite %04d", err, w.idx)
+				}
+
+				if !packer.Fits(trimmed.Rect.Size()) {
+					if err := writeMegaPage(megaPageFilename(outFn, page), pageImg, packer.Size()); err != nil {
+						return err
+					}
+					page++
+					pageImg = image.NewRGBA(image.Rect(0, 0, megaPageSize, megaPageSize))
+					packer = atlas.NewPacker(megaPageSize, megaPageSize, opts)
+				}
+
+				bbox := packer.Place(trimmed.Rect.Size())
+				draw.Draw(pageImg, bbox, trimmed, image.Point{}, draw.Over)
+
+				infos = append(infos, megaFrameInfo{
+					Sprite:  w.idx,
+					Anim:    animIdx,
+					Frame:   frameIdx,
+					Page:    page,
+					X:       bbox.Min.X,
+					Y:       bbox.Min.Y,
+					W:       bbox.Dx(),
+					H:       bbox.Dy(),
+					OriginX: origin.X,
+					OriginY: origin.Y,
+					Delay:   int(frame.Delay),
+					Action:  int(fctrlAction(frame.Action)),
+
+					AnimName: anim.Name,
+				})
+			}
+		}
+	}
+
+	if size := packer.Size(); size.X > 0 && size.Y > 0 {
+		if err := writeMegaPage(megaPageFilename(outFn, page), pageImg, size); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(outFn+"/mega.json", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	})
+}
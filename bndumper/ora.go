@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
+)
+
+type oraLayer struct {
+	XMLName xml.Name `xml:"layer"`
+	Name    string   `xml:"name,attr"`
+	Src     string   `xml:"src,attr"`
+	X       int      `xml:"x,attr"`
+	Y       int      `xml:"y,attr"`
+}
+
+type oraImage struct {
+	XMLName xml.Name `xml:"image"`
+	Version string   `xml:"version,attr"`
+	W       int      `xml:"w,attr"`
+	H       int      `xml:"h,attr"`
+	Stack   struct {
+		Layers []oraLayer
+	} `xml:"stack"`
+}
+
+func layeredFilename(outFn string, idx int, animIdx int, frameIdx int) string {
+	return fmt.Sprintf("%s/%04d_%02d_%02d.ora", outFn, idx, animIdx, frameIdx)
+}
+
+func writeZipPNG(zw *zip.Writer, name string, img image.Image) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// thumbnail shrinks img to fit in 256x256 by nearest neighbor, as OpenRaster requires.
+func thumbnail(img *image.Paletted) *image.Paletted {
+	scale := 1
+	for img.Rect.Dx() > 256*scale || img.Rect.Dy() > 256*scale {
+		scale++
+	}
+	if scale == 1 {
+		return img
+	}
+
+	thumb := image.NewPaletted(image.Rect(0, 0, img.Rect.Dx()/scale, img.Rect.Dy()/scale), img.Palette)
+	for y := 0; y < thumb.Rect.Dy(); y++ {
+		for x := 0; x < thumb.Rect.Dx(); x++ {
+			thumb.SetColorIndex(x, y, img.ColorIndexAt(img.Rect.Min.X+x*scale, img.Rect.Min.Y+y*scale))
+		}
+	}
+	return thumb
+}
+
+// writeLayeredFrame writes a frame as an OpenRaster document with one layer per OAM object, topmost object first.
+func writeLayeredFrame(w io.Writer, frame sprites.Frame) error {
+	merged, err := frame.MakeImage()
+	if err != nil {
+		return err
+	}
+
+	center := image.Point{merged.Rect.Dx() / 2, merged.Rect.Dy() / 2}
+
+	var extent image.Rectangle
+	objRects := make([]image.Rectangle, len(frame.OAMEntries))
+	for i, ent := range frame.OAMEntries {
+		objRects[i] = image.Rect(ent.X, ent.Y, ent.X+ent.WTiles*8, ent.Y+ent.HTiles*8).Add(center).Intersect(merged.Rect)
+		extent = extent.Union(objRects[i])
+	}
+
+	if extent.Empty() {
+		return fmt.Errorf("%w: frame has no objects on screen", sprites.ErrOutOfRange)
+	}
+
+	zw := zip.NewWriter(w)
+
+	// The mimetype has to come first and be stored uncompressed.
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mw, "image/openraster"); err != nil {
+		return err
+	}
+
+	doc := oraImage{Version: "0.0.5", W: extent.Dx(), H: extent.Dy()}
+
+	for i := len(frame.OAMEntries) - 1; i >= 0; i-- {
+		layer, err := frame.MakeImageLayer(sprites.ObjectIndexes(i))
+		if err != nil {
+			return err
+		}
+
+		src := fmt.Sprintf("data/object%02d.png", i)
+		if err := writeZipPNG(zw, src, layer.SubImage(objRects[i])); err != nil {
+			return err
+		}
+
+		doc.Stack.Layers = append(doc.Stack.Layers, oraLayer{
+			Name: fmt.Sprintf("object %d", i),
+			Src:  src,
+			X:    objRects[i].Min.X - extent.Min.X,
+			Y:    objRects[i].Min.Y - extent.Min.Y,
+		})
+	}
+
+	sw, err := zw.Create("stack.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(sw, xml.Header); err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(sw).Encode(doc); err != nil {
+		return err
+	}
+
+	mergedCropped := image.NewPaletted(image.Rect(0, 0, extent.Dx(), extent.Dy()), merged.Palette)
+	paletted.DrawOver(mergedCropped, mergedCropped.Rect, merged, extent.Min)
+
+	if err := writeZipPNG(zw, "mergedimage.png", mergedCropped); err != nil {
+		return err
+	}
+
+	if err := writeZipPNG(zw, "Thumbnails/thumbnail.png", thumbnail(mergedCropped)); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeLayeredFrames(outFn string, idx int, anims []sprites.Animation) error {
+	for animIdx, anim := range anims {
+		for frameIdx, frame := range anim.Frames {
+			if len(frame.OAMEntries) == 0 {
+				continue
+			}
+
+			if err := writeFileAtomic(layeredFilename(outFn, idx, animIdx, frameIdx), func(w io.Writer) error {
+				return writeLayeredFrame(w, frame)
+			}); err != nil {
+				return fmt.Errorf("%w while writing frame %d of animation %d", err, frameIdx, animIdx)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+var overlayColors = []color.NRGBA{
+	{0xff, 0x00, 0x00, 0xff},
+	{0x00, 0xc0, 0x00, 0xff},
+	{0x00, 0x80, 0xff, 0xff},
+	{0xff, 0xa0, 0x00, 0xff},
+	{0xc0, 0x00, 0xff, 0xff},
+}
+
+func debugOverlayFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d_debug.png", outFn, idx)
+}
+
+// makeDebugOverlay draws every frame's box, index and origin over a copy of the sheet, to show how frames were packed.
+func makeDebugOverlay(sheet *spritesheet) *image.NRGBA {
+	img := image.NewNRGBA(sheet.Image.Rect)
+	draw.Draw(img, img.Rect, sheet.Image, image.Point{}, draw.Src)
+
+	for i, info := range sheet.Frames {
+		if info.BBox.Empty() {
+			continue
+		}
+		c := overlayColors[i%len(overlayColors)]
+		r := info.BBox
+
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, r.Min.Y, c)
+			img.SetNRGBA(x, r.Max.Y-1, c)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			img.SetNRGBA(r.Min.X, y, c)
+			img.SetNRGBA(r.Max.X-1, y, c)
+		}
+
+		// Label on a dark backing so it reads over any sprite colors.
+		label := image.Rect(r.Min.X+1, r.Min.Y+1, r.Min.X+2+len(strconv.Itoa(i))*4, r.Min.Y+8).Intersect(r)
+		draw.Draw(img, label, image.NewUniform(color.NRGBA{0, 0, 0, 0xc0}), image.Point{}, draw.Over)
+		drawDigits(img, r.Min.Add(image.Point{2, 2}), i, c)
+
+		if origin := r.Min.Add(info.Origin); origin.In(r) {
+			img.SetNRGBA(origin.X, origin.Y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+
+	return img
+}
+
+func countCheckFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d_count.png", outFn, idx)
+}
+
+// digitsWidth is how wide drawDigits draws n.
+func digitsWidth(n int) int {
+	return len(strconv.Itoa(n))*4 - 1
+}
+
+// makeCountCheck copies the sheet with a strip added underneath holding the sprite index in white and the total frame count in yellow, so bulk reviews can spot sprites that came out short without opening their metadata.
+func makeCountCheck(sheet *spritesheet) *image.NRGBA {
+	const stripH = 7
+
+	idxW := digitsWidth(sheet.Index)
+	w := 1 + idxW + 4 + digitsWidth(len(sheet.Frames)) + 1
+	if sheet.Image.Rect.Dx() > w {
+		w = sheet.Image.Rect.Dx()
+	}
+	h := sheet.Image.Rect.Dy()
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h+stripH))
+	draw.Draw(img, sheet.Image.Rect, sheet.Image, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, h, w, h+stripH), image.NewUniform(color.NRGBA{0, 0, 0, 0xff}), image.Point{}, draw.Src)
+
+	drawDigits(img, image.Point{1, h + 1}, sheet.Index, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	drawDigits(img, image.Point{1 + idxW + 4, h + 1}, len(sheet.Frames), color.NRGBA{0xff, 0xe0, 0x00, 0xff})
+
+	return img
+}
+
+func writeCountCheck(outFn string, sheet *spritesheet) error {
+	return writeFileAtomic(countCheckFilename(outFn, sheet.Index), func(w io.Writer) error {
+		return png.Encode(w, makeCountCheck(sheet))
+	})
+}
+
+func writeDebugOverlay(outFn string, sheet *spritesheet) error {
+	return writeFileAtomic(debugOverlayFilename(outFn, sheet.Index), func(w io.Writer) error {
+		return png.Encode(w, makeDebugOverlay(sheet))
+	})
+}
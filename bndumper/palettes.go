@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+
+	"github.com/murkland/bnrom/palettes"
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/gbarom"
+)
+
+func writePaletteFile(fn string, p color.Palette, write func(io.Writer, color.Palette) error) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		return write(w, p)
+	})
+}
+
+func readLospecFile(fn string) (color.Palette, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return sprites.LoadLospec(f)
+}
+
+func samePalette(a color.Palette, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if color.RGBAModel.Convert(a[i]) != color.RGBAModel.Convert(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// dumpPalettes writes every distinct frame palette of every sprite as .act, .pal and .hex, named <sprite>_<palette>, and with -palette_swatches, a <sprite>.png showing them all.
+func dumpPalettes(ctx context.Context, r io.ReadSeeker, outFn string) error {
+	romID, err := gbarom.ReadROMID(r)
+	if err != nil {
+		return err
+	}
+
+	info := findSpriteInfo(romID)
+	if info == nil {
+		return errors.New("unsupported game")
+	}
+
+	os.Mkdir(outFn, 0o700)
+
+	bar := newProgress("palettes", info.Count)
+	return sprites.Iterate(r, *info, func(idx int, anims []sprites.Animation) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w while dumping palettes", err)
+		}
+
+		bar.step(idx)
+
+		var seen []color.Palette
+		for _, anim := range anims {
+			for _, frame := range anim.Frames {
+				if len(frame.Palette) == 0 {
+					continue
+				}
+
+				dup := false
+				for _, p := range seen {
+					if samePalette(p, frame.Palette) {
+						dup = true
+						break
+					}
+				}
+				if dup {
+					continue
+				}
+
+				fn := fmt.Sprintf("%s/%04d_%02d", outFn, idx, len(seen))
+				seen = append(seen, frame.Palette)
+
+				if err := writePaletteFile(fn+".pal", frame.Palette, palettes.WriteJASC); err != nil {
+					return err
+				}
+
+				if err := writePaletteFile(fn+".hex", frame.Palette, sprites.WriteLospec); err != nil {
+					return err
+				}
+
+				if err := writePaletteFile(fn+".act", frame.Palette, palettes.WriteACT); err != nil {
+					if !errors.Is(err, palettes.ErrTooManyColors) {
+						return err
+					}
+					os.Remove(fn + ".act")
+					warnf("%s: %s, only writing .pal", fn, err)
+				}
+			}
+		}
+
+		if *paletteSwatchesF && len(seen) > 0 {
+			if err := writeSwatches(fmt.Sprintf("%s/%04d.png", outFn, idx), seen); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// newProgressBar is progressbar.Default, except that it never writes to stdout so stdout stays free for -stdout.
+func newProgressBar(max int64) *progressbar.ProgressBar {
+	bar := progressbar.NewOptions64(
+		max,
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionThrottle(65*time.Millisecond),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprintf(os.Stderr, "\n")
+		}),
+		progressbar.OptionSpinnerType(14),
+		progressbar.OptionFullWidth(),
+	)
+	bar.RenderBlank()
+	return bar
+}
+
+type progressEvent struct {
+	Stage  string `json:"stage"`
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+var progressMu sync.Mutex
+
+// progress reports how far along a stage is, in whichever way -progress asks for.
+type progress struct {
+	stage string
+	bar   *progressbar.ProgressBar
+}
+
+func newProgress(stage string, max int) *progress {
+	p := &progress{stage: stage}
+	if *progressF == "bar" {
+		p.bar = newProgressBar(int64(max))
+		p.bar.Describe(stage)
+	}
+	return p
+}
+
+// step marks that work on item idx has started.
+func (p *progress) step(idx int) {
+	if p.bar != nil {
+		p.bar.Add(1)
+		p.bar.Describe(fmt.Sprintf("%s: %04d", p.stage, idx))
+	}
+}
+
+// report records how item idx turned out. It only shows up with -progress jsonl.
+func (p *progress) report(idx int, status string, err error) {
+	if *progressF != "jsonl" {
+		return
+	}
+
+	ev := progressEvent{Stage: p.stage, Index: idx, Status: status}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	json.NewEncoder(os.Stderr).Encode(ev)
+}
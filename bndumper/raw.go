@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/gbarom"
+)
+
+// dumpRawSprites writes the bytes each sprite is parsed from to <outFn>/<sprite>.bin, for checking the parser against a disassembly.
+func dumpRawSprites(ctx context.Context, r io.ReadSeeker, outFn string) error {
+	romID, err := gbarom.ReadROMID(r)
+	if err != nil {
+		return err
+	}
+
+	info := findSpriteInfo(romID)
+	if info == nil {
+		return errors.New("unsupported game")
+	}
+
+	if _, err := r.Seek(info.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outFn, 0o700); err != nil {
+		return err
+	}
+
+	dumped, failed := 0, 0
+	bar := newProgress("raw", info.Count)
+	for i := 0; i < info.Count; i++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w while dumping raw sprites", err)
+		}
+
+		bar.step(i)
+		if *spriteF >= 0 && i != *spriteF {
+			if _, err := r.Seek(info.EntrySize(), io.SeekCurrent); err != nil {
+				return err
+			}
+			bar.report(i, "skipped", nil)
+			continue
+		}
+
+		raw, err := info.ReadRaw(r)
+		if err != nil {
+			if !sprites.IsDecodeError(err) {
+				return fmt.Errorf("%w while reading sprite %04d", err, i)
+			}
+			warnf("error reading %04d: %s", i, err)
+			bar.report(i, "failed", err)
+			failed++
+			continue
+		}
+
+		if err := writeFileAtomic(fmt.Sprintf("%s/%04d.bin", outFn, i), func(w io.Writer) error {
+			_, err := w.Write(raw)
+			return err
+		}); err != nil {
+			return err
+		}
+		bar.report(i, "dumped", nil)
+		dumped++
+	}
+
+	infof("Raw sprites: %d dumped, %d failed", dumped, failed)
+	return nil
+}
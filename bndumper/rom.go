@@ -0,0 +1,91 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+type memROM struct {
+	*bytes.Reader
+}
+
+func (memROM) Close() error {
+	return nil
+}
+
+// openROM opens a ROM file, or a zip or gzip file holding one, which is decompressed into memory. Archives are told apart by their magic bytes rather than their extension. A zip file is read from its first .gba entry.
+func openROM(fn string) (io.ReadSeekCloser, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	magic, err := bufio.NewReader(f).Peek(4)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		defer f.Close()
+
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return nil, fmt.Errorf("%w while opening zip file", err)
+		}
+
+		for _, zf := range zr.File {
+			if !strings.EqualFold(path.Ext(zf.Name), ".gba") {
+				continue
+			}
+
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%w while opening %s in zip file", err, zf.Name)
+			}
+			defer rc.Close()
+
+			buf, err := io.ReadAll(rc)
+			if err != nil {
+				return nil, fmt.Errorf("%w while decompressing %s in zip file", err, zf.Name)
+			}
+			return memROM{bytes.NewReader(buf)}, nil
+		}
+
+		return nil, fmt.Errorf("no .gba file in zip file")
+
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		defer f.Close()
+
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%w while opening gzip file", err)
+		}
+
+		buf, err := io.ReadAll(gr)
+		if err != nil {
+			return nil, fmt.Errorf("%w while decompressing gzip file", err)
+		}
+		return memROM{bytes.NewReader(buf)}, nil
+	}
+
+	return f, nil
+}
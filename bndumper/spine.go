@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+const spineSlot = "sprite"
+
+type spineSkeleton struct {
+	Spine  string `json:"spine"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Images string `json:"images"`
+}
+
+type spineBone struct {
+	Name string `json:"name"`
+}
+
+type spineSlotInfo struct {
+	Name string `json:"name"`
+	Bone string `json:"bone"`
+}
+
+type spineRegion struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+}
+
+type spineSkin struct {
+	Name        string                            `json:"name"`
+	Attachments map[string]map[string]spineRegion `json:"attachments"`
+}
+
+type spineAttachmentKey struct {
+	Time float64 `json:"time"`
+	// Name is nil to hide the slot, for frames with nothing to draw.
+	Name *string `json:"name"`
+}
+
+type spineSlotTimelines struct {
+	Attachment []spineAttachmentKey `json:"attachment"`
+}
+
+type spineEventKey struct {
+	Time float64 `json:"time"`
+	Name string  `json:"name"`
+}
+
+type spineAnimation struct {
+	Slots  map[string]spineSlotTimelines `json:"slots"`
+	Events []spineEventKey               `json:"events,omitempty"`
+}
+
+type spineEvent struct{}
+
+type spineDocument struct {
+	Skeleton   spineSkeleton             `json:"skeleton"`
+	Bones      []spineBone               `json:"bones"`
+	Slots      []spineSlotInfo           `json:"slots"`
+	Skins      []spineSkin               `json:"skins"`
+	Events     map[string]spineEvent     `json:"events,omitempty"`
+	Animations map[string]spineAnimation `json:"animations"`
+}
+
+func spineFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d.spine.json", outFn, idx)
+}
+
+func spineAtlasFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d.atlas", outFn, idx)
+}
+
+// spineEventName names a frame action as a Spine event: loop or stop, with any event bits added in hex.
+func spineEventName(ev sprites.AtlasEvent) string {
+	name := "next"
+	switch ev.Action {
+	case 1:
+		name = "loop"
+	case 2:
+		name = "stop"
+	}
+	if ev.Event != 0 {
+		name += fmt.Sprintf("_%04x", ev.Event)
+	}
+	return name
+}
+
+func spineRegionName(i int) string {
+	return fmt.Sprintf("frame%03d", i)
+}
+
+// writeSpineAtlas writes a libGDX-style texture atlas with one region per frame of the sheet, which is what Spine reads attachments from.
+func writeSpineAtlas(outFn string, idx int, sheetSize image.Point, infos []frameInfo) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", filepath.Base(spriteFilename(outFn, idx)))
+	fmt.Fprintf(&b, "size: %d,%d\n", sheetSize.X, sheetSize.Y)
+	b.WriteString("format: RGBA8888\nfilter: Nearest,Nearest\nrepeat: none\n")
+
+	for i, info := range infos {
+		if info.BBox.Empty() {
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n", spineRegionName(i))
+		b.WriteString("  rotate: false\n")
+		fmt.Fprintf(&b, "  xy: %d, %d\n", info.BBox.Min.X, info.BBox.Min.Y)
+		fmt.Fprintf(&b, "  size: %d, %d\n", info.BBox.Dx(), info.BBox.Dy())
+		fmt.Fprintf(&b, "  orig: %d, %d\n", info.BBox.Dx(), info.BBox.Dy())
+		b.WriteString("  offset: 0, 0\n  index: -1\n")
+	}
+
+	return writeFileAtomic(spineAtlasFilename(outFn, idx), func(w io.Writer) error {
+		_, err := io.WriteString(w, b.String())
+		return err
+	})
+}
+
+// writeSpineSkeleton writes a Spine skeleton with a single bone at the sprite's origin and a single slot that switches between frame attachments. OAM objects aren't split into slots of their own: frames are drawn whole, as in the sheet.
+func writeSpineSkeleton(outFn string, idx int, infos []frameInfo) error {
+	doc := spineDocument{
+		Skeleton:   spineSkeleton{Spine: "3.8", Images: "./"},
+		Bones:      []spineBone{{"root"}},
+		Slots:      []spineSlotInfo{{spineSlot, "root"}},
+		Animations: map[string]spineAnimation{},
+	}
+
+	attachments := map[string]spineRegion{}
+	for i, info := range infos {
+		if info.BBox.Empty() {
+			continue
+		}
+
+		if info.BBox.Dx() > doc.Skeleton.Width {
+			doc.Skeleton.Width = info.BBox.Dx()
+		}
+		if info.BBox.Dy() > doc.Skeleton.Height {
+			doc.Skeleton.Height = info.BBox.Dy()
+		}
+
+		// Spine places region attachments by their center, with y pointing up.
+		attachments[spineRegionName(i)] = spineRegion{
+			X:      float64(info.BBox.Dx())/2 - float64(info.Origin.X),
+			Y:      float64(info.Origin.Y) - float64(info.BBox.Dy())/2,
+			Width:  info.BBox.Dx(),
+			Height: info.BBox.Dy(),
+		}
+	}
+	doc.Skins = []spineSkin{{"default", map[string]map[string]spineRegion{spineSlot: attachments}}}
+
+	for animIdx, ar := range animRanges(infos) {
+		name := ar.Name
+		if name == "" {
+			name = fmt.Sprintf("anim%02d", animIdx)
+		}
+
+		var keys []spineAttachmentKey
+		times := make([]float64, ar.Count)
+		ticks := 0
+		for i := ar.Start; i < ar.Start+ar.Count; i++ {
+			times[i-ar.Start] = sprites.TicksToDuration(ticks).Seconds()
+			key := spineAttachmentKey{Time: times[i-ar.Start]}
+			if !infos[i].BBox.Empty() {
+				region := spineRegionName(i)
+				key.Name = &region
+			}
+			keys = append(keys, key)
+			ticks += infos[i].Delay
+		}
+
+		// Events fire when their frame is shown.
+		var events []spineEventKey
+		for _, ev := range ar.Events {
+			evName := spineEventName(ev)
+			if doc.Events == nil {
+				doc.Events = map[string]spineEvent{}
+			}
+			doc.Events[evName] = spineEvent{}
+			events = append(events, spineEventKey{times[ev.Frame], evName})
+		}
+
+		doc.Animations[name] = spineAnimation{map[string]spineSlotTimelines{spineSlot: {keys}}, events}
+	}
+
+	return writeFileAtomic(spineFilename(outFn, idx), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	})
+}
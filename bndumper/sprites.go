@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -10,11 +11,13 @@ import (
 	"image/draw"
 	"image/png"
 	"io"
-	"log"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/murkland/bnrom/atlas"
 	"github.com/murkland/bnrom/paletted"
 	"github.com/murkland/bnrom/sprites"
 	"github.com/murkland/gbarom"
@@ -22,108 +25,502 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func processOneSheet(outFn string, idx int, anims []sprites.Animation) error {
-	type frameInfo struct {
-		BBox   image.Rectangle
-		Origin image.Point
-		Delay  int
-		Action sprites.FrameAction
+type frameInfo struct {
+	Anim   int
+	BBox   image.Rectangle
+	Origin image.Point
+	Delay  int
+	Action sprites.FrameAction
+	Event  sprites.Event
+
+	AnimName string
+}
+
+// animRanges groups consecutive frames of the same animation, so consumers can tell where each animation starts in the flat frame list. Each range also gets the event track sprites.Animation.Events would give for it.
+func animRanges(infos []frameInfo) []sprites.AtlasAnimation {
+	var ranges []sprites.AtlasAnimation
+	for i, info := range infos {
+		if i == 0 || info.Anim != infos[i-1].Anim {
+			ranges = append(ranges, sprites.AtlasAnimation{Start: i, Name: info.AnimName})
+		}
+		ar := &ranges[len(ranges)-1]
+		if info.Action != sprites.FrameActionNext {
+			ar.Events = append(ar.Events, sprites.AtlasEvent{Frame: i - ar.Start, Action: int(fctrlAction(info.Action)), Event: int(info.Event)})
+		}
+		ar.Count++
 	}
+	return ranges
+}
+
+// fctrlAction encodes an action as the JSON and other text formats have it: 0 for next, 1 for loop and 2 for stop, ignoring any event bits. fctrl chunks have the whole action instead.
+func fctrlAction(action sprites.FrameAction) uint8 {
+	switch action & sprites.FrameActionLoop {
+	case sprites.FrameActionLoop:
+		return 1
+	case sprites.FrameActionStop:
+		return 2
+	}
+	return 0
+}
+
+// makeFctrlFrameInfo encodes a frame of a sheet as it goes in the fctrl chunk.
+func makeFctrlFrameInfo(info frameInfo) fctrlFrameInfo {
+	return fctrlFrameInfo{
+		int16(info.BBox.Min.X),
+		int16(info.BBox.Min.Y),
+		int16(info.BBox.Max.X),
+		int16(info.BBox.Max.Y),
+		int16(info.Origin.X),
+		int16(info.Origin.Y),
+		uint16(info.Delay),
+		info.Action,
+	}
+}
+
+// renderFrame renders a frame with its own palette, or remapped into globalPalette if it isn't nil, and applies -crop.
+func renderFrame(frame sprites.Frame, globalPalette color.Palette) (*image.Paletted, error) {
+	img, err := renderWholeFrame(frame, globalPalette)
+	if err != nil {
+		return nil, err
+	}
+	if crop != nil {
+		cropFrame(img, frame)
+	}
+	return img, nil
+}
+
+func renderWholeFrame(frame sprites.Frame, globalPalette color.Palette) (*image.Paletted, error) {
+	if globalPalette != nil {
+		// A quantized master palette is missing colors by design.
+		if *masterPaletteF {
+			return frame.MakeImageWithPalette(globalPalette)
+		}
+		return frame.MakeImageInto(globalPalette)
+	}
+	return frame.MakeImage()
+}
+
+// crop is the parsed -crop relative to the frame's origin, or nil if frames are kept whole.
+var crop *image.Rectangle
+
+func parseCrop(s string) (image.Rectangle, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return image.Rectangle{}, fmt.Errorf("crop must be x,y,w,h: %q", s)
+	}
+
+	var v [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("crop must be x,y,w,h: %q", s)
+		}
+		v[i] = n
+	}
+	if v[2] <= 0 || v[3] <= 0 {
+		return image.Rectangle{}, fmt.Errorf("crop must have a positive width and height: %q", s)
+	}
+
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
+
+// cropFrame clears every pixel of a rendered frame outside -crop to the background, so trimming then shrinks the frame to what's left and the origin comes out relative to that.
+func cropFrame(img *image.Paletted, frame sprites.Frame) {
+	keep := crop.Add(frame.CanvasOrigin())
+
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			if !(image.Point{x, y}).In(keep) {
+				img.SetColorIndex(x, y, 0)
+			}
+		}
+	}
+}
+
+// renderTrimmedFrame renders a frame trimmed to its visible pixels, along with where the frame's origin lies relative to the trimmed image.
+func renderTrimmedFrame(frame sprites.Frame, globalPalette color.Palette) (*image.Paletted, image.Point, error) {
+	img, err := renderFrame(frame, globalPalette)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+
+	trimBbox := paletted.FindTrimThreshold(img, uint8(*trimMinAlphaF))
+
+	origin := frame.CanvasOrigin().Sub(trimBbox.Min)
+
+	trimmed := image.NewPaletted(image.Rect(0, 0, trimBbox.Dx(), trimBbox.Dy()), img.Palette)
+	paletted.DrawOver(trimmed, trimmed.Rect, img, trimBbox.Min)
+
+	return trimmed, origin, nil
+}
+
+// renderUntrimmedFrame renders a frame on its whole canvas, as MakeImage does, for -no_trim. The origin is wherever the frame's origin lands on the canvas, normally its center.
+func renderUntrimmedFrame(frame sprites.Frame, globalPalette color.Palette) (*image.Paletted, image.Point, error) {
+	img, err := renderFrame(frame, globalPalette)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	return img, frame.CanvasOrigin(), nil
+}
 
-	left := 0
-	top := 0
+// spritesheet is every frame of a sprite packed into one image, along with the metadata that goes into its PNG chunks.
+type spritesheet struct {
+	Index int
+	Image *image.Paletted
 
+	// FullPalette is the sprite's whole palette, which may go past the 256 colors that fit in Image's palette.
+	FullPalette color.Palette
+	Frames      []frameInfo
+
+	// Grid is set if the sheet was laid out with -grid.
+	Grid *sprites.AtlasGrid
+}
+
+// buildSheet packs every frame of a sprite into one sheet. It returns a nil sheet if the sprite has nothing to draw.
+func buildSheet(idx int, anims []sprites.Animation, globalPalette color.Palette) (*spritesheet, error) {
 	var infos []frameInfo
+	var frameImgs []*image.Paletted
+	var frames []sprites.Frame
 	var fullPalette color.Palette
-	spriteImg := image.NewPaletted(image.Rect(0, 0, 2048, 2048), nil)
+	var palette color.Palette
 
-	for _, anim := range anims {
-		for _, frame := range anim.Frames {
-			fullPalette = frame.Palette
+	packer := atlas.NewPacker(2048, 0, atlas.PackOptions{
+		PowerOfTwo: *powerOfTwoF,
+		Padding:    *paddingF,
+		Align:      *alignF,
+	})
+
+	for animIdx, anim := range anims {
+		for frameIdx, frame := range anim.Frames {
+			hasPalette := len(frame.Palette) > 0 || globalPalette != nil
+			if !hasPalette {
+				warnf("sprite %04d: frame %d of animation %d has no palette data", idx, frameIdx, animIdx)
+			} else if globalPalette != nil {
+				fullPalette = globalPalette
+			} else {
+				fullPalette = frame.Palette
+			}
 
 			var fi frameInfo
+			fi.Anim = animIdx
+			fi.AnimName = anim.Name
 			fi.Delay = int(frame.Delay)
 			fi.Action = frame.Action
+			fi.Event = frame.Event()
+
+			render := renderTrimmedFrame
+			if *noTrimF {
+				render = renderUntrimmedFrame
+			}
+
+			trimmed, origin, err := render(frame, globalPalette)
+			if err != nil {
+				return nil, fmt.Errorf("%w while rendering sprite %04d", err, idx)
+			}
+			if hasPalette {
+				palette = trimmed.Palette
+			}
 
-			img := frame.MakeImage()
-			spriteImg.Palette = img.Palette
+			fi.Origin = origin
+			if !*gridF {
+				fi.BBox = packer.Place(trimmed.Rect.Size())
+			}
+
+			infos = append(infos, fi)
+			frameImgs = append(frameImgs, trimmed)
+			frames = append(frames, frame)
+		}
+	}
+
+	numFrames := 0
+	for _, anim := range anims {
+		numFrames += len(anim.Frames)
+	}
+	if len(infos) != numFrames {
+		return nil, fmt.Errorf("sprite %04d has %d frames but %d were packed", idx, numFrames, len(infos))
+	}
 
-			trimBbox := paletted.FindTrim(img)
+	if palette == nil {
+		return nil, nil
+	}
+
+	size := packer.Size()
+	var grid *sprites.AtlasGrid
+	if *gridF {
+		grid, size = layoutGrid(infos, frameImgs, anims)
+	}
+	if size.X == 0 || size.Y == 0 {
+		return nil, nil
+	}
 
-			fi.Origin.X = img.Rect.Dx()/2 - trimBbox.Min.X
-			fi.Origin.Y = img.Rect.Dy()/2 - trimBbox.Min.Y
+	subimg := image.NewPaletted(image.Rectangle{Max: size}, palette)
+	for i, fi := range infos {
+		paletted.DrawOver(subimg, fi.BBox, frameImgs[i], image.Point{})
+	}
 
-			if left+trimBbox.Dx() > spriteImg.Rect.Dx() {
-				left = 0
-				top = paletted.FindTrim(spriteImg).Max.Y
-				top++
+	if *validateF {
+		for i, fi := range infos {
+			if err := validateFrame(subimg, frames[i], fi, globalPalette); err != nil {
+				if errors.Is(err, errOriginOutside) && !*strictOriginF {
+					warnf("sprite %04d: frame %d: %s", idx, i, err)
+					continue
+				}
+				return nil, fmt.Errorf("%w in frame %d of sprite %04d", err, i, idx)
 			}
+		}
+	}
+
+	return &spritesheet{idx, subimg, fullPalette, infos, grid}, nil
+}
 
-			fi.BBox = image.Rectangle{image.Point{left, top}, image.Point{left + trimBbox.Dx(), top + trimBbox.Dy()}}
+// layoutGrid lays the frames out one animation per row, in cells all the same size, with every frame's origin at the same point in its cell. It pads every frame image out to its cell.
+func layoutGrid(infos []frameInfo, frameImgs []*image.Paletted, anims []sprites.Animation) (*sprites.AtlasGrid, image.Point) {
+	var cell image.Rectangle
+	for i, fi := range infos {
+		if !frameImgs[i].Rect.Empty() {
+			cell = cell.Union(frameImgs[i].Rect.Sub(fi.Origin))
+		}
+	}
 
-			draw.Draw(spriteImg, fi.BBox, img, trimBbox.Min, draw.Over)
-			infos = append(infos, fi)
+	grid := &sprites.AtlasGrid{Rows: len(anims), CellW: cell.Dx(), CellH: cell.Dy()}
+	for _, anim := range anims {
+		if len(anim.Frames) > grid.Cols {
+			grid.Cols = len(anim.Frames)
+		}
+	}
+
+	i := 0
+	for animIdx, anim := range anims {
+		for frameIdx := range anim.Frames {
+			padded := image.NewPaletted(image.Rect(0, 0, cell.Dx(), cell.Dy()), frameImgs[i].Palette)
+			at := infos[i].Origin.Mul(-1).Sub(cell.Min)
+			paletted.DrawOver(padded, frameImgs[i].Rect.Add(at), frameImgs[i], image.Point{})
+			frameImgs[i] = padded
+
+			infos[i].Origin = cell.Min.Mul(-1)
+			infos[i].BBox = image.Rect(frameIdx*cell.Dx(), animIdx*cell.Dy(), (frameIdx+1)*cell.Dx(), (animIdx+1)*cell.Dy())
+			i++
+		}
+	}
+
+	return grid, image.Point{grid.Cols * cell.Dx(), grid.Rows * cell.Dy()}
+}
+
+// errOriginOutside is returned by validateFrame for a frame whose origin isn't inside its box in the sheet.
+var errOriginOutside = errors.New("origin is outside the frame")
+
+// validateFrame checks that every pixel of a packed frame is where its origin says it should be and has the right color, by walking the frame's box in the sheet and comparing against a fresh untrimmed render, and that nothing the render draws falls outside the box. Once the pixels check out, it fails with errOriginOutside if the origin isn't inside the box, which the caller only warns about without -strict_origin, since effects drawn entirely above the sprite's anchor have their origin outside.
+func validateFrame(subimg *image.Paletted, frame sprites.Frame, fi frameInfo, globalPalette color.Palette) error {
+	img, err := renderFrame(frame, globalPalette)
+	if err != nil {
+		return err
+	}
 
-			if trimBbox.Dx() > 0 {
-				left += trimBbox.Dx() + 1
+	offset := frame.CanvasOrigin().Sub(fi.BBox.Min.Add(fi.Origin))
+
+	// Frames without palette data are already logged, and have no colors to check.
+	checkColors := len(frame.Palette) > 0 || globalPalette != nil
+
+	// Every pixel that survives trimming has to have made it into the sheet.
+	if trim := paletted.FindTrimThreshold(img, uint8(*trimMinAlphaF)); !trim.Empty() && !trim.Sub(offset).In(fi.BBox) {
+		return fmt.Errorf("rendered frame covers %s, which the origin maps to %s, outside the frame's box %s", trim, trim.Sub(offset), fi.BBox)
+	}
+
+	for y := fi.BBox.Min.Y; y < fi.BBox.Max.Y; y++ {
+		for x := fi.BBox.Min.X; x < fi.BBox.Max.X; x++ {
+			src := image.Point{x, y}.Add(offset)
+			if !src.In(img.Rect) {
+				return fmt.Errorf("sheet pixel (%d, %d) maps to (%d, %d), outside the rendered frame", x, y, src.X, src.Y)
+			}
+			if got, want := subimg.ColorIndexAt(x, y), img.ColorIndexAt(src.X, src.Y); got != want {
+				return fmt.Errorf("sheet pixel (%d, %d) is %d, but the origin maps it to (%d, %d) which is %d", x, y, got, src.X, src.Y, want)
+			}
+			// The sheet only has one palette, so a frame with a different one comes out in the wrong colors even with the right indexes.
+			if !checkColors {
+				continue
+			}
+			if idx := int(subimg.ColorIndexAt(x, y)); idx >= len(subimg.Palette) || idx >= len(img.Palette) {
+				return fmt.Errorf("sheet pixel (%d, %d) is %d, past the end of the sheet's or the frame's palette", x, y, idx)
+			}
+			if got, want := color.NRGBAModel.Convert(subimg.At(x, y)), color.NRGBAModel.Convert(img.At(src.X, src.Y)); got != want {
+				return fmt.Errorf("sheet pixel (%d, %d) is %v, but the frame draws it as %v: the frame's palette differs from the sheet's", x, y, got, want)
 			}
 		}
 	}
 
-	if spriteImg.Palette == nil {
-		return nil
+	if origin := fi.BBox.Min.Add(fi.Origin); !fi.BBox.Empty() && !origin.In(fi.BBox) {
+		return fmt.Errorf("%w: %s isn't inside the frame's box %s", errOriginOutside, origin, fi.BBox)
 	}
 
-	subimg := spriteImg.SubImage(paletted.FindTrim(spriteImg))
-	if subimg.Bounds().Dx() == 0 || subimg.Bounds().Dy() == 0 {
-		return nil
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// WriteTo writes the sheet as a PNG, with its frame metadata packed in before the image data.
+func (s *spritesheet) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := s.writePNG(cw)
+	return cw.n, err
+}
+
+// colorKey is the parsed -color_key, or nil if transparency is kept.
+var colorKey *color.NRGBA
+
+// remapPalette is the palette read from -remap_palette with a transparent entry in front, or nil if sheets keep their own colors.
+var remapPalette color.Palette
+
+func parseColorKey(s string) (color.NRGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return color.NRGBA{}, fmt.Errorf("color must be 6 hex digits: %q", s)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// encodedImage converts the sheet to the PNG color type given by -pngtype. Grayscale PNGs as written by image/png have no alpha channel, so transparent pixels come out black.
+func (s *spritesheet) encodedImage() (image.Image, error) {
+	src := s.Image
+	if remapPalette != nil {
+		src = sprites.Remap(src, remapPalette)
 	}
-	f, err := os.Create(fmt.Sprintf("%s/%04d.png", outFn, idx))
+	if colorKey != nil {
+		var err error
+		src, err = s.colorKeyed(src, *colorKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch *pngTypeF {
+	case "rgba":
+		img := image.NewNRGBA(src.Rect)
+		draw.Draw(img, img.Rect, src, image.Point{}, draw.Src)
+		return img, nil
+
+	case "gray":
+		img := image.NewGray(src.Rect)
+		for i, p := range src.Pix {
+			c := color.NRGBAModel.Convert(src.Palette[p]).(color.NRGBA)
+			if c.A == 0 {
+				continue
+			}
+			if c.R != c.G || c.G != c.B {
+				return nil, fmt.Errorf("%w: sprite %04d has color %v, but -pngtype gray needs every color to be gray", sprites.ErrUnsupportedFormat, s.Index, c)
+			}
+			img.Pix[i] = c.R
+		}
+		return img, nil
+	}
+
+	return src, nil
+}
+
+// colorKeyed returns the sheet with every transparent palette entry replaced by key, for tools that want a color key rather than alpha. It fails if the sheet already draws key somewhere, since those pixels would turn transparent.
+func (s *spritesheet) colorKeyed(img *image.Paletted, key color.NRGBA) (*image.Paletted, error) {
+	used := make([]bool, len(img.Palette))
+	for _, p := range img.Pix {
+		used[p] = true
+	}
+
+	palette := make(color.Palette, len(img.Palette))
+	for i, c := range img.Palette {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		if nc.A == 0 {
+			palette[i] = key
+			continue
+		}
+		if used[i] && nc == key {
+			return nil, fmt.Errorf("%w: sprite %04d already uses the color key %v at index %d", sprites.ErrUnsupportedFormat, s.Index, key, i)
+		}
+		palette[i] = c
+	}
+
+	return &image.Paletted{Pix: img.Pix, Stride: img.Stride, Rect: img.Rect, Palette: palette}, nil
+}
+
+func (s *spritesheet) writePNG(w io.Writer) error {
+	img, err := s.encodedImage()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
 	pipeR, pipeW := io.Pipe()
+	defer pipeR.Close()
 
 	var g errgroup.Group
 
 	g.Go(func() error {
 		defer pipeW.Close()
-		if err := png.Encode(pipeW, subimg); err != nil {
+		if err := png.Encode(pipeW, img); err != nil {
 			return err
 		}
 		return nil
 	})
 
-	pngr, err := pngchunks.NewReader(pipeR)
+	if err := s.copyPNGChunks(pipeR, w); err != nil {
+		return err
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// copyPNGChunks copies the PNG in r to w chunk by chunk, adding the sheet's metadata chunks on the way.
+func (s *spritesheet) copyPNGChunks(r io.Reader, w io.Writer) error {
+	pngr, err := pngchunks.NewReader(r)
 	if err != nil {
 		return err
 	}
 
-	pngw, err := pngchunks.NewWriter(f)
+	pngw, err := pngchunks.NewWriter(w)
 	if err != nil {
 		return err
 	}
 
-	var metaWritten bool
+	var metaWritten, colorSpaceWritten, ended bool
 	for {
 		chunk, err := pngr.NextChunk()
 		if err != nil {
 			if errors.Is(err, io.EOF) {
+				if !ended {
+					return fmt.Errorf("%w: png ends before its IEND chunk", io.ErrUnexpectedEOF)
+				}
 				break
 			}
+			return fmt.Errorf("%w while reading png chunk", err)
+		}
+		ended = chunk.Type() == "IEND"
+
+		// Color space chunks have to come before the palette as well as the image data.
+		if *srgbF && !colorSpaceWritten && (chunk.Type() == "PLTE" || chunk.Type() == "IDAT") {
+			if err := writeColorSpaceChunks(pngw); err != nil {
+				return err
+			}
+			colorSpaceWritten = true
 		}
 
 		if chunk.Type() == "IDAT" && !metaWritten {
 			// Pack metadata in here.
-			if len(fullPalette) > 256 {
+			if len(s.FullPalette) > 256 {
 				var buf bytes.Buffer
 				buf.WriteString("extra")
 				buf.WriteByte('\x00')
 				buf.WriteByte('\x08')
-				for _, c := range fullPalette[256:] {
-					binary.Write(&buf, binary.LittleEndian, c.(color.RGBA))
+				for _, c := range s.FullPalette[256:] {
+					binary.Write(&buf, binary.LittleEndian, color.RGBAModel.Convert(c).(color.RGBA))
 					buf.WriteByte('\x00')
 					buf.WriteByte('\x00')
 				}
@@ -137,34 +534,47 @@ func processOneSheet(outFn string, idx int, anims []sprites.Animation) error {
 				buf.WriteString("fctrl")
 				buf.WriteByte('\x00')
 				buf.WriteByte('\xff')
-				for _, info := range infos {
-					var action uint8
-					switch info.Action {
-					case sprites.FrameActionNext:
-						action = 0
-					case sprites.FrameActionLoop:
-						action = 1
-					case sprites.FrameActionStop:
-						action = 2
-					}
+				buf.WriteByte(fctrlVersion)
+				for _, info := range s.Frames {
+					binary.Write(&buf, binary.LittleEndian, makeFctrlFrameInfo(info))
+				}
+				if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
+					return err
+				}
+			}
 
-					binary.Write(&buf, binary.LittleEndian, fctrlFrameInfo{
-						int16(info.BBox.Min.X),
-						int16(info.BBox.Min.Y),
-						int16(info.BBox.Max.X),
-						int16(info.BBox.Max.Y),
-						int16(info.Origin.X),
-						int16(info.Origin.Y),
-						uint8(info.Delay),
-						action,
-					})
+			{
+				// fctrl is left as it was so existing readers keep working, and animation ranges go in their own chunk instead.
+				var buf bytes.Buffer
+				buf.WriteString("fanim")
+				buf.WriteByte('\x00')
+				buf.WriteByte('\xff')
+				buf.WriteByte(fanimVersion)
+				for _, ar := range animRanges(s.Frames) {
+					binary.Write(&buf, binary.LittleEndian, fanimRange{uint16(ar.Start), uint16(ar.Count), uint8(len(ar.Name))})
+					buf.WriteString(ar.Name)
 				}
 				if err := pngw.WriteChunk(int32(buf.Len()), "zTXt", bytes.NewBuffer(buf.Bytes())); err != nil {
 					return err
 				}
+			}
 
-				metaWritten = true
+			if *textMetaF {
+				var buf bytes.Buffer
+				buf.WriteString("bnrom")
+				buf.WriteByte('\x00')
+				fmt.Fprintf(&buf, "sprite=%04d\n", s.Index)
+				fmt.Fprintf(&buf, "frames=%d\n", len(s.Frames))
+				buf.WriteString("frame,delay,action\n")
+				for i, info := range s.Frames {
+					fmt.Fprintf(&buf, "%d,%d,0x%04x\n", i, info.Delay, uint16(info.Action))
+				}
+				if err := pngw.WriteChunk(int32(buf.Len()), "tEXt", bytes.NewBuffer(buf.Bytes())); err != nil {
+					return err
+				}
 			}
+
+			metaWritten = true
 		}
 
 		if err := pngw.WriteChunk(chunk.Length(), chunk.Type(), chunk); err != nil {
@@ -176,70 +586,482 @@ func processOneSheet(outFn string, idx int, anims []sprites.Animation) error {
 		}
 	}
 
-	if err := g.Wait(); err != nil {
+	return nil
+}
+
+// writeColorSpaceChunks declares the image as sRGB, along with the gAMA and cHRM values the PNG spec says should go with an sRGB chunk for decoders that don't understand it. This is off by default: it makes every file a little bigger, and what gamma the GBA's screen really had is debatable anyway.
+func writeColorSpaceChunks(pngw *pngchunks.Writer) error {
+	// Perceptual rendering intent.
+	if err := pngw.WriteChunk(1, "sRGB", bytes.NewReader([]byte{0})); err != nil {
+		return err
+	}
+
+	var gama bytes.Buffer
+	binary.Write(&gama, binary.BigEndian, uint32(45455))
+	if err := pngw.WriteChunk(int32(gama.Len()), "gAMA", &gama); err != nil {
+		return err
+	}
+
+	var chrm bytes.Buffer
+	binary.Write(&chrm, binary.BigEndian, [8]uint32{31270, 32900, 64000, 33000, 30000, 60000, 15000, 6000})
+	return pngw.WriteChunk(int32(chrm.Len()), "cHRM", &chrm)
+}
+
+// writeSheetFile writes a sheet to fn, replacing it only once the whole sheet has been written.
+func writeSheetFile(fn string, sheet *spritesheet) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		_, err := sheet.WriteTo(w)
+		return err
+	})
+}
+
+func processOneSheet(outFn string, idx int, anims []sprites.Animation, enemies []sprites.AtlasEnemy, globalPalette color.Palette) error {
+	sheet, err := buildSheet(idx, anims, globalPalette)
+	if err != nil {
+		return err
+	}
+
+	if *modeF == "layered" {
+		if err := writeLayeredFrames(outFn, idx, anims); err != nil {
+			return fmt.Errorf("%w while writing layered frames for sprite %04d", err, idx)
+		}
+	}
+
+	if *modeF == "aligned" {
+		if err := writeAlignedAnims(outFn, idx, anims); err != nil {
+			return fmt.Errorf("%w while writing aligned animations for sprite %04d", err, idx)
+		}
+	}
+
+	if *modeF == "diff" {
+		if err := writeFrameDiffs(outFn, idx, anims); err != nil {
+			return fmt.Errorf("%w while writing frame diffs for sprite %04d", err, idx)
+		}
+	}
+
+	if *modeF == "onion" {
+		if err := writeOnionSkins(outFn, idx, anims); err != nil {
+			return fmt.Errorf("%w while writing onion skins for sprite %04d", err, idx)
+		}
+	}
+
+	if exp := exporters[*exportF]; exp.Name() != "png" {
+		err := writeFileAtomic(spriteOutputFilename(outFn, idx), func(w io.Writer) error {
+			return exp.Export(w, idx, anims)
+		})
+		if errors.Is(err, errNothingToExport) {
+			return nil
+		}
+		return err
+	}
+
+	if sheet == nil {
+		return nil
+	}
+
+	if err := writeSheetFile(spriteFilename(outFn, idx), sheet); err != nil {
 		return err
 	}
 
+	if *validateOutputF {
+		if err := validateSheetFile(spriteFilename(outFn, idx), sheet); err != nil {
+			return err
+		}
+	}
+
+	if *debugOverlayF {
+		if err := writeDebugOverlay(outFn, sheet); err != nil {
+			return fmt.Errorf("%w while writing debug overlay", err)
+		}
+	}
+
+	if *countCheckF {
+		if err := writeCountCheck(outFn, sheet); err != nil {
+			return fmt.Errorf("%w while writing count check", err)
+		}
+	}
+
+	if *formatF == "tiled" {
+		if err := writeTiledTileset(outFn, idx, sheet.Image.Rect.Size(), sheet.Frames); err != nil {
+			return fmt.Errorf("%w while writing tileset", err)
+		}
+	}
+
+	if *formatF == "spine" {
+		if err := writeSpineAtlas(outFn, idx, sheet.Image.Rect.Size(), sheet.Frames); err != nil {
+			return fmt.Errorf("%w while writing spine atlas", err)
+		}
+		if err := writeSpineSkeleton(outFn, idx, sheet.Frames); err != nil {
+			return fmt.Errorf("%w while writing spine skeleton", err)
+		}
+	}
+
+	if *formatF == "json" || *modeF == "html" {
+		// Every animation of a sprite has the sprite's kind.
+		kind := sprites.UnknownKind
+		if len(anims) > 0 {
+			kind = anims[0].Kind()
+		}
+		if err := writeSheetJSON(outFn, idx, kind, enemies, sheet.Image.Rect.Size(), sheet.Frames, sheet.Grid); err != nil {
+			return fmt.Errorf("%w while writing sheet json", err)
+		}
+	}
+
 	return nil
 }
 
-func dumpSprites(r io.ReadSeeker, outFn string) error {
+// colorAdjust is the -brightness and -gamma adjustment, applied to every palette that's dumped.
+var colorAdjust sprites.ColorAdjust
+
+// decodeOptions is the -strict, -max_anim_frames and -max_frame_dim checks sprites are decoded with.
+var decodeOptions sprites.DecodeOptions
+
+// findSpriteInfo is sprites.FindROMInfo, with the flags' checks and adjustments applied to what's decoded.
+func findSpriteInfo(romID string) *sprites.ROMInfo {
+	info := sprites.FindROMInfo(romID)
+	if info != nil {
+		info.Decode = decodeOptions
+		info.Adjust = colorAdjust
+	}
+	return info
+}
+
+func diffSprites(r io.ReadSeeker, info sprites.ROMInfo, otherFn string) ([]int, error) {
+	f, err := openROM(otherFn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	otherROMID, err := gbarom.ReadROMID(f)
+	if err != nil {
+		return nil, err
+	}
+
+	otherInfo := findSpriteInfo(otherROMID)
+	if otherInfo == nil {
+		return nil, errors.New("unsupported game")
+	}
+
+	return sprites.Diff(sprites.NewReader(r, info), sprites.NewReader(f, *otherInfo))
+}
+
+type work struct {
+	idx     int
+	anims   []sprites.Animation
+	enemies []sprites.AtlasEnemy
+}
+
+func spriteFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d.png", outFn, idx)
+}
+
+// spriteOutputFilename is where the sprite's main output goes, which is the sheet unless -export picks another format.
+func spriteOutputFilename(outFn string, idx int) string {
+	return fmt.Sprintf("%s/%04d.%s", outFn, idx, *exportF)
+}
+
+// selectAnim narrows a sprite down to the animation picked with -anim, which then comes out as animation 0. Without -anim, it returns anims as is.
+func selectAnim(idx int, anims []sprites.Animation) ([]sprites.Animation, error) {
+	if *animF < 0 {
+		return anims, nil
+	}
+	if *animF >= len(anims) {
+		return nil, fmt.Errorf("sprite %04d has %d animations, so there's no animation %d", idx, len(anims), *animF)
+	}
+	return anims[*animF : *animF+1], nil
+}
+
+// dumpSpriteToStdout writes the sheet for the sprite selected with -sprite to stdout, or with -export, whatever that exporter writes.
+func dumpSpriteToStdout(r io.ReadSeeker) error {
 	romID, err := gbarom.ReadROMID(r)
 	if err != nil {
 		return err
 	}
 
-	info := sprites.FindROMInfo(romID)
+	info := findSpriteInfo(romID)
+	if info == nil {
+		return errors.New("unsupported game")
+	}
+
+	anims, err := sprites.NewReader(r, *info).Sprite(*spriteF)
+	if err != nil {
+		return err
+	}
+	nameAnims(*spriteF, anims)
+	if anims, err = selectAnim(*spriteF, anims); err != nil {
+		return err
+	}
+
+	if *exportF != "png" {
+		err := exporters[*exportF].Export(os.Stdout, *spriteF, anims)
+		if errors.Is(err, errNothingToExport) {
+			return fmt.Errorf("sprite %04d is empty", *spriteF)
+		}
+		return err
+	}
+
+	var globalPalette color.Palette
+	if *globalPaletteF {
+		globalPalette, err = sprites.BuildGlobalPalette([][]sprites.Animation{anims})
+		if err != nil {
+			return fmt.Errorf("%w while building global palette, try without -global_palette", err)
+		}
+	}
+
+	sheet, err := buildSheet(*spriteF, anims, globalPalette)
+	if err != nil {
+		return err
+	}
+
+	if sheet == nil {
+		return fmt.Errorf("sprite %04d is empty", *spriteF)
+	}
+
+	_, err = sheet.WriteTo(os.Stdout)
+	return err
+}
+
+func dumpSprites(ctx context.Context, r io.ReadSeeker, outFn string) error {
+	romID, err := gbarom.ReadROMID(r)
+	if err != nil {
+		return err
+	}
+
+	info := findSpriteInfo(romID)
 	if info == nil {
 		return errors.New("unsupported game")
 	}
 
+	manifestFn := outFn + "/manifest.json"
+	m, err := readManifest(manifestFn)
+	if err != nil {
+		return fmt.Errorf("%w while reading manifest", err)
+	}
+	// Outputs from another game, or made with other options, are all stale, whatever their sprites hash to.
+	if opts := optionsDigest(); m.ROMID != romID || m.Options != opts {
+		m = &manifest{Version: manifestVersion, ROMID: romID, Options: opts, Sprites: map[int]manifestEntry{}}
+	}
+
+	var enemyLabels map[int][]sprites.AtlasEnemy
+	if enemyTable := findEnemyTable(romID); enemyTable != nil {
+		enemyLabels, err = readEnemyLabels(r, *enemyTable)
+		if err != nil {
+			return fmt.Errorf("%w while reading enemy table", err)
+		}
+	}
+
+	if *spriteF >= info.Count {
+		return fmt.Errorf("sprite %d out of range, game has %d sprites", *spriteF, info.Count)
+	}
+
+	var onlySprites map[int]bool
+	if *spriteF >= 0 {
+		onlySprites = map[int]bool{*spriteF: true}
+	}
+
+	if *diffF != "" {
+		changed, err := diffSprites(r, *info, *diffF)
+		if err != nil {
+			return fmt.Errorf("%w while diffing against %s", err, *diffF)
+		}
+
+		diffed := map[int]bool{}
+		for _, i := range changed {
+			if onlySprites == nil || onlySprites[i] {
+				diffed[i] = true
+			}
+		}
+		onlySprites = diffed
+	}
+
 	if _, err := r.Seek(info.Offset, os.SEEK_SET); err != nil {
 		return err
 	}
 
-	s := make([][]sprites.Animation, 0, info.Count)
+	s := make([]work, 0, info.Count)
+	skipped := 0
+	empty := 0
+	failed := 0
+
+	// Placeholder slots tend to share one pointer, so remember which pointers turned out empty rather than decoding them again.
+	emptyPtrs := map[uint32]bool{}
 
-	bar1 := progressbar.Default(int64(info.Count))
-	bar1.Describe("decode")
+	bar1 := newProgress("decode", info.Count)
 	for i := 0; i < info.Count; i++ {
-		bar1.Add(1)
-		bar1.Describe(fmt.Sprintf("decode: %04d", i))
-		anims, err := sprites.ReadNext(r)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w while decoding sprites", err)
+		}
+
+		bar1.step(i)
+		if onlySprites != nil && !onlySprites[i] {
+			if _, err := r.Seek(info.EntrySize(), io.SeekCurrent); err != nil {
+				return err
+			}
+			debugf("sprite %04d: skipped", i)
+			bar1.report(i, "skipped", nil)
+			skipped++
+			continue
+		}
+
+		var ptr uint32
+		if *skipEmptyF {
+			var err error
+			ptr, err = info.ReadPointer(r)
+			if err != nil {
+				return fmt.Errorf("%w while reading pointer for sprite %04d", err, i)
+			}
+			if ptr == info.PointerBase || emptyPtrs[ptr] {
+				debugf("sprite %04d: skipped empty slot 0x%08x", i, ptr)
+				bar1.report(i, "empty", nil)
+				empty++
+				continue
+			}
+			if _, err := r.Seek(-info.EntrySize(), io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+
+		anims, err := info.ReadNext(r, i)
 		if err != nil {
-			log.Printf("error reading %04d: %s", i, err)
+			if !sprites.IsDecodeError(err) {
+				return fmt.Errorf("%w while reading sprite %04d", err, i)
+			}
+			warnf("error reading %04d: %s", i, err)
+			bar1.report(i, "failed", err)
+			failed++
+			continue
+		}
+		if minLogLevel <= levelDebug {
+			numFrames, numCompressed := 0, 0
+			for _, anim := range anims {
+				numFrames += len(anim.Frames)
+				for _, frame := range anim.Frames {
+					if frame.Compressed() {
+						numCompressed++
+					}
+				}
+			}
+			debugf("sprite %04d: %d animations, %d frames, %d with compressed tiles", i, len(anims), numFrames, numCompressed)
+		}
+
+		if *skipEmptyF && sprites.IsEmpty(anims) {
+			debugf("sprite %04d: skipped empty sprite 0x%08x", i, ptr)
+			bar1.report(i, "empty", nil)
+			emptyPtrs[ptr] = true
+			empty++
+			continue
+		}
+
+		nameAnims(i, anims)
+		if anims, err = selectAnim(i, anims); err != nil {
+			return err
+		}
+
+		// The sprite has to be decoded to know whether it changed, but that's still much less than dumping it again.
+		if *skipExistingF && m.upToDate(i, spriteOutputFilename(outFn, i), sourceHash(anims)) {
+			debugf("sprite %04d: skipped, output is up to date", i)
+			bar1.report(i, "skipped", nil)
+			skipped++
 			continue
 		}
-		s = append(s, anims)
+
+		s = append(s, work{i, anims, enemyLabels[i]})
 	}
 
 	os.Mkdir(outFn, 0o700)
 
-	bar2 := progressbar.Default(int64(len(s)))
-	bar2.Describe("dump")
-	type work struct {
-		idx   int
-		anims []sprites.Animation
+	if enemyLabels != nil {
+		if err := dumpEnemyLabels(enemyLabels, outFn+"/enemies.json"); err != nil {
+			return fmt.Errorf("%w while writing enemies.json", err)
+		}
+	}
+
+	if *modeF == "timing-csv" {
+		if err := writeTimingCSV(outFn+"/timing.csv", s); err != nil {
+			return fmt.Errorf("%w while writing timing.csv", err)
+		}
+		infof("Sprites: timing for %d written, %d skipped, %d empty, %d failed", len(s), skipped, empty, failed)
+		return nil
+	}
+
+	if *megaF {
+		if err := dumpMegaAtlas(ctx, s, outFn); err != nil {
+			return err
+		}
+		infof("Sprites: %d dumped into mega atlas, %d skipped, %d empty, %d failed", len(s), skipped, empty, failed)
+		return nil
+	}
+
+	var globalPalette color.Palette
+	if *globalPaletteF {
+		allAnims := make([][]sprites.Animation, len(s))
+		for i, w := range s {
+			allAnims[i] = w.anims
+		}
+
+		globalPalette, err = sprites.BuildGlobalPalette(allAnims)
+		if err != nil {
+			return fmt.Errorf("%w while building global palette, try without -global_palette", err)
+		}
+	}
+
+	if *masterPaletteF {
+		allAnims := make([][]sprites.Animation, len(s))
+		for i, w := range s {
+			allAnims[i] = w.anims
+		}
+
+		globalPalette = sprites.BuildMasterPalette(allAnims, 256)
+		if err := writeMasterPalette(ctx, outFn, s, globalPalette); err != nil {
+			return fmt.Errorf("%w while writing master palette", err)
+		}
 	}
 
+	bar2 := newProgress("dump", len(s))
+
+	// invalid counts sheets that failed -validate_output. They're still dumped, so the run only fails at the end.
+	var invalid int64
+	// dumped and dumpFailed count the sprites whose output was written and the ones that failed to render, which only shows once they're dumped.
+	var dumped, dumpFailed int64
+
 	ch := make(chan work, runtime.NumCPU())
 
-	var g errgroup.Group
+	g, gctx := errgroup.WithContext(ctx)
 	for i := 0; i < runtime.NumCPU(); i++ {
 		g.Go(func() error {
 			for w := range ch {
-				bar2.Add(1)
-				bar2.Describe(fmt.Sprintf("dump: %04d", w.idx))
-				if err := processOneSheet(outFn, w.idx, w.anims); err != nil {
-					return err
+				bar2.step(w.idx)
+				if err := processOneSheet(outFn, w.idx, w.anims, w.enemies, globalPalette); err != nil {
+					if errors.Is(err, errInvalidOutput) {
+						warnf("sprite %04d: %s", w.idx, err)
+						bar2.report(w.idx, "invalid", err)
+						atomic.AddInt64(&invalid, 1)
+						continue
+					}
+					if !sprites.IsDecodeError(err) {
+						return err
+					}
+					warnf("error dumping %04d: %s", w.idx, err)
+					bar2.report(w.idx, "failed", err)
+					atomic.AddInt64(&dumpFailed, 1)
+					continue
 				}
+				bar2.report(w.idx, "dumped", nil)
+				atomic.AddInt64(&dumped, 1)
 			}
 			return nil
 		})
 	}
 
-	for spriteIdx, anims := range s {
-		ch <- work{spriteIdx, anims}
+	// On interrupt, stop handing out sprites but let the workers finish the ones they're on. Also stop if a worker fails, since the workers that are left might all have failed.
+feed:
+	for _, w := range s {
+		select {
+		case ch <- w:
+		case <-gctx.Done():
+			break feed
+		}
 	}
 	close(ch)
 
@@ -247,5 +1069,44 @@ func dumpSprites(r io.ReadSeeker, outFn string) error {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w while dumping sprites", err)
+	}
+
+	for _, w := range s {
+		if *animF >= 0 {
+			// Output with only one animation mustn't look up to date to a later full run.
+			delete(m.Sprites, w.idx)
+			continue
+		}
+
+		h, err := hashFile(spriteOutputFilename(outFn, w.idx))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Empty sprites don't produce any output.
+				delete(m.Sprites, w.idx)
+				continue
+			}
+			return err
+		}
+		m.Sprites[w.idx] = manifestEntry{Source: sourceHash(w.anims), Output: h}
+	}
+
+	if err := writeManifest(manifestFn, m); err != nil {
+		return fmt.Errorf("%w while writing manifest", err)
+	}
+
+	if *modeF == "html" {
+		if err := writeHTMLIndex(outFn); err != nil {
+			return fmt.Errorf("%w while writing index.html", err)
+		}
+	}
+
+	infof("Sprites: %d dumped, %d skipped, %d empty, %d failed", dumped, skipped, empty, failed+int(dumpFailed))
+
+	if invalid > 0 {
+		return fmt.Errorf("%w: %d sheets", errInvalidOutput, invalid)
+	}
+
 	return nil
 }
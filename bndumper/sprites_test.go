@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/bnrom/sprites/spritestest"
+	"github.com/murkland/pngchunks"
+)
+
+// readChunk returns the data of the first chunk of type typ in the PNG in buf.
+func readChunk(t *testing.T, buf []byte, typ string) []byte {
+	t.Helper()
+
+	pngr, err := pngchunks.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		chunk, err := pngr.NextChunk()
+		if errors.Is(err, io.EOF) {
+			t.Fatalf("no %s chunk", typ)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chunk.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.Type() == typ {
+			return data
+		}
+	}
+}
+
+func TestSheetSPLTWithGamma(t *testing.T) {
+	raw := make([]byte, 300*2)
+	for i := range raw {
+		raw[i] = byte(i)
+	}
+	full, err := sprites.DecodePalette(raw, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full = sprites.ColorAdjust{Gamma: 2.2}.Palette(full)
+
+	sheet := &spritesheet{
+		Image:       image.NewPaletted(image.Rect(0, 0, 4, 4), full[:256]),
+		FullPalette: full,
+	}
+
+	var buf bytes.Buffer
+	if _, err := sheet.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	splt := readChunk(t, buf.Bytes(), "sPLT")
+	header := []byte("extra\x00\x08")
+	if !bytes.HasPrefix(splt, header) {
+		t.Fatalf("sPLT starts with %q, want %q", splt[:len(header)], header)
+	}
+	entries := splt[len(header):]
+	if len(entries) != (300-256)*6 {
+		t.Fatalf("sPLT has %d bytes of entries, want %d", len(entries), (300-256)*6)
+	}
+	for i, c := range full[256:] {
+		want := color.RGBAModel.Convert(c).(color.RGBA)
+		e := entries[i*6:]
+		if got := (color.RGBA{e[0], e[1], e[2], e[3]}); got != want {
+			t.Errorf("sPLT entry %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestValidateOriginOutsideBox(t *testing.T) {
+	defer func(validate, strict bool) { *validateF, *strictOriginF = validate, strict }(*validateF, *strictOriginF)
+	*validateF = true
+	*strictOriginF = true
+
+	makeAnims := func(x, y int) []sprites.Animation {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = 1
+		}
+		return []sprites.Animation{{Frames: []sprites.Frame{{
+			Palette:    color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}},
+			Action:     sprites.FrameActionStop,
+			Tiles:      []*image.Paletted{tile},
+			OAMEntries: []sprites.OAMEntry{{X: x, Y: y, WTiles: 1, HTiles: 1}},
+		}}}}
+	}
+
+	if _, err := buildSheet(0, makeAnims(-4, -4), nil); err != nil {
+		t.Errorf("buildSheet with the origin inside the frame: %s", err)
+	}
+
+	// The object is drawn entirely below and to the right of the anchor.
+	if _, err := buildSheet(0, makeAnims(8, 8), nil); !errors.Is(err, errOriginOutside) {
+		t.Errorf("buildSheet with the origin outside the frame = %v, want errOriginOutside", err)
+	}
+
+	*strictOriginF = false
+	if _, err := buildSheet(0, makeAnims(8, 8), nil); err != nil {
+		t.Errorf("buildSheet without -strict_origin: %s", err)
+	}
+}
+
+func TestBuildSheetExplicitOrigin(t *testing.T) {
+	defer func(validate bool) { *validateF = validate }(*validateF)
+	*validateF = true
+
+	tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+	for i := range tile.Pix {
+		tile.Pix[i] = 1
+	}
+	frame := sprites.Frame{
+		Palette:    color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}},
+		Action:     sprites.FrameActionStop,
+		Tiles:      []*image.Paletted{tile},
+		OAMEntries: []sprites.OAMEntry{{WTiles: 1, HTiles: 1}},
+	}
+	frame.SetOrigin(image.Point{4, 5})
+
+	sheet, err := buildSheet(0, []sprites.Animation{{Frames: []sprites.Frame{frame}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The object is drawn from the anchor with no offset, so without SetOrigin the origin would be the box's top left.
+	if got, want := sheet.Frames[0].Origin, (image.Point{4, 5}); got != want {
+		t.Errorf("origin = %s, want %s", got, want)
+	}
+}
+
+func TestSheetMatchesMakeImage(t *testing.T) {
+	stop := uint16(sprites.FrameActionStop)
+	objs := func(o ...spritestest.Object) []spritestest.Object { return o }
+	rom := spritestest.ROM([]int{0, 1, 2},
+		spritestest.Sprite([]spritestest.Frame{{Objects: objs(spritestest.Object{Fill: 1, X: -4, Y: -8}), Delay: 2, Action: stop}}),
+		// Objects apart from each other and some overlapping, across two animations.
+		spritestest.Sprite(
+			[]spritestest.Frame{
+				{Objects: objs(spritestest.Object{Fill: 2, X: -16, Y: -16}, spritestest.Object{Fill: 3, X: 8, Y: 4}), Delay: 3},
+				{Objects: objs(spritestest.Object{Fill: 4, X: -2, Y: -2}, spritestest.Object{Fill: 5, X: 2, Y: 2}), Delay: 3, Action: uint16(sprites.FrameActionLoop)},
+			},
+			[]spritestest.Frame{
+				{Objects: objs(spritestest.Object{Fill: 6, X: 20, Y: -30}), Delay: 1, Action: stop},
+			},
+		),
+		spritestest.Sprite([]spritestest.Frame{
+			{Objects: objs(spritestest.Object{Fill: 7, X: -40, Y: 10}, spritestest.Object{Fill: 8, X: -40, Y: 18}, spritestest.Object{Fill: 9, X: -32, Y: 10}), Action: stop},
+		}),
+	)
+
+	s, err := sprites.Read(bytes.NewReader(rom), int64(len(rom)), sprites.ROMInfo{Count: 3})
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	for idx, anims := range s {
+		sheet, err := buildSheet(idx, anims, nil)
+		if err != nil {
+			t.Fatalf("sprite %d: buildSheet: %s", idx, err)
+		}
+
+		i := 0
+		for animIdx, anim := range anims {
+			for frameIdx, frame := range anim.Frames {
+				fi := sheet.Frames[i]
+				i++
+
+				img, err := frame.MakeImage()
+				if err != nil {
+					t.Fatalf("sprite %d: MakeImage: %s", idx, err)
+				}
+				trim := paletted.FindTrim(img)
+				if trim.Size() != fi.BBox.Size() {
+					t.Errorf("sprite %d animation %d frame %d: box %s is %s, but MakeImage trims to %s", idx, animIdx, frameIdx, fi.BBox, fi.BBox.Size(), trim.Size())
+					continue
+				}
+				if got, want := fi.Origin, frame.CanvasOrigin().Sub(trim.Min); got != want {
+					t.Errorf("sprite %d animation %d frame %d: origin = %s, want %s", idx, animIdx, frameIdx, got, want)
+				}
+
+				sub := sheet.Image.SubImage(fi.BBox).(*image.Paletted)
+				for y := 0; y < trim.Dy(); y++ {
+					for x := 0; x < trim.Dx(); x++ {
+						got := sub.At(fi.BBox.Min.X+x, fi.BBox.Min.Y+y)
+						want := img.At(trim.Min.X+x, trim.Min.Y+y)
+						if color.NRGBAModel.Convert(got) != color.NRGBAModel.Convert(want) {
+							t.Fatalf("sprite %d animation %d frame %d: pixel (%d, %d) of the box is %v, want %v", idx, animIdx, frameIdx, x, y, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCopyPNGChunksTruncated(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 16, 16), color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}})
+	img.Pix[17] = 1
+	sheet := &spritesheet{Image: img, FullPalette: img.Palette, Frames: []frameInfo{{BBox: img.Rect}}}
+
+	var full bytes.Buffer
+	if err := png.Encode(&full, img); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []int{full.Len() - 20, full.Len() - 12, 40} {
+		if err := sheet.copyPNGChunks(bytes.NewReader(full.Bytes()[:n]), io.Discard); err == nil {
+			t.Errorf("copying the first %d of %d bytes succeeded", n, full.Len())
+		}
+	}
+}
+
+func TestColorKeyed(t *testing.T) {
+	key := color.NRGBA{0xff, 0, 0xff, 0xff}
+	img := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.RGBA{}, color.RGBA{0x10, 0x20, 0x30, 0xff}, color.RGBA{0xff, 0, 0xff, 0xff}})
+	img.Pix[1] = 1
+	sheet := &spritesheet{Index: 7, Image: img}
+
+	keyed, err := sheet.colorKeyed(img, key)
+	if err != nil {
+		t.Fatalf("colorKeyed with the key in the palette but not drawn: %s", err)
+	}
+	if got := keyed.At(0, 0); got != key {
+		t.Errorf("transparent pixel = %v, want the key %v", got, key)
+	}
+	if got, want := color.NRGBAModel.Convert(keyed.At(1, 0)), (color.NRGBA{0x10, 0x20, 0x30, 0xff}); got != want {
+		t.Errorf("opaque pixel = %v, want %v", got, want)
+	}
+
+	img.Pix[0] = 2
+	if _, err := sheet.colorKeyed(img, key); !errors.Is(err, sprites.ErrUnsupportedFormat) {
+		t.Errorf("colorKeyed with the key drawn = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestBuildSheetCrop(t *testing.T) {
+	defer func(c *image.Rectangle) { crop = c }(crop)
+	keep := image.Rect(0, 0, 16, 16)
+	crop = &keep
+
+	tile := func(fill uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = fill
+		}
+		return tile
+	}
+	frame := sprites.Frame{
+		Palette: color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0xff, 0, 0xff}},
+		Action:  sprites.FrameActionStop,
+		Tiles:   []*image.Paletted{tile(1), tile(2)},
+		OAMEntries: []sprites.OAMEntry{
+			{TileIndex: 0, X: -16, Y: -16, WTiles: 1, HTiles: 1},
+			{TileIndex: 1, X: 8, Y: 8, WTiles: 1, HTiles: 1},
+		},
+	}
+
+	sheet, err := buildSheet(0, []sprites.Animation{{Frames: []sprites.Frame{frame}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi := sheet.Frames[0]
+	if fi.BBox.Size() != (image.Point{8, 8}) {
+		t.Fatalf("box = %s, want just the 8x8 object inside the crop", fi.BBox)
+	}
+	if fi.Origin != (image.Point{-8, -8}) {
+		t.Errorf("origin = %s, want (-8,-8), relative to the object left after cropping", fi.Origin)
+	}
+	for y := fi.BBox.Min.Y; y < fi.BBox.Max.Y; y++ {
+		for x := fi.BBox.Min.X; x < fi.BBox.Max.X; x++ {
+			if got := sheet.Image.ColorIndexAt(x, y); got != 2 {
+				t.Fatalf("pixel (%d, %d) = %d, want 2 from the object inside the crop", x, y, got)
+			}
+		}
+	}
+}
+
+func TestTrimMinAlpha(t *testing.T) {
+	defer func(old int) { *trimMinAlphaF = old }(*trimMinAlphaF)
+
+	tile := func(fill uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = fill
+		}
+		return tile
+	}
+	// Object 1 is a faint shadow, at 0x40 alpha, to the right of the opaque object 0.
+	frame := sprites.Frame{
+		Palette: color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0, 0, 0x40}},
+		Action:  sprites.FrameActionStop,
+		Tiles:   []*image.Paletted{tile(1), tile(2)},
+		OAMEntries: []sprites.OAMEntry{
+			{TileIndex: 0, X: -8, Y: -8, WTiles: 1, HTiles: 1},
+			{TileIndex: 1, X: 0, Y: -8, WTiles: 1, HTiles: 1},
+		},
+	}
+
+	for _, tc := range []struct {
+		minAlpha   int
+		wantSize   image.Point
+		wantOrigin image.Point
+	}{
+		{1, image.Pt(16, 8), image.Pt(8, 8)},
+		{0x40, image.Pt(16, 8), image.Pt(8, 8)},
+		{0x41, image.Pt(8, 8), image.Pt(8, 8)},
+		{0xff, image.Pt(8, 8), image.Pt(8, 8)},
+	} {
+		*trimMinAlphaF = tc.minAlpha
+		img, origin, err := renderTrimmedFrame(frame, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := img.Rect.Size(); got != tc.wantSize {
+			t.Errorf("-trim_min_alpha %d: trimmed to %s, want %s", tc.minAlpha, got, tc.wantSize)
+		}
+		if origin != tc.wantOrigin {
+			t.Errorf("-trim_min_alpha %d: origin = %s, want %s", tc.minAlpha, origin, tc.wantOrigin)
+		}
+	}
+}
+
+func TestCancelStopsDumpAndCheck(t *testing.T) {
+	const romID = "TSTE"
+	sprites.KnownGames[romID] = sprites.GameInfo{Title: "test", ROMInfo: sprites.ROMInfo{Offset: spritestest.HeaderSize, Count: 2}}
+	defer delete(sprites.KnownGames, romID)
+	defer func(old string) { *progressF = old }(*progressF)
+	*progressF = "none"
+
+	rom := spritestest.GameROM(romID, []int{0, 0}, spritestest.Sprite([]spritestest.Frame{
+		{Objects: []spritestest.Object{{Fill: 1, X: -4, Y: -4}}, Action: uint16(sprites.FrameActionStop)},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outFn := t.TempDir()
+	if err := dumpSprites(ctx, bytes.NewReader(rom), outFn); !errors.Is(err, context.Canceled) {
+		t.Errorf("dumpSprites with a cancelled context = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(spriteFilename(outFn, 0)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("dumpSprites wrote sprite 0 after being cancelled")
+	}
+
+	if _, err := checkSprites(ctx, bytes.NewReader(rom)); !errors.Is(err, context.Canceled) {
+		t.Errorf("checkSprites with a cancelled context = %v, want context.Canceled", err)
+	}
+
+	// The same ROM goes through both without a cancelled context, so it's the cancellation that stopped them.
+	if err := dumpSprites(context.Background(), bytes.NewReader(rom), outFn); err != nil {
+		t.Fatalf("dumpSprites: %s", err)
+	}
+	if _, err := os.Stat(spriteFilename(outFn, 0)); err != nil {
+		t.Errorf("dumpSprites didn't write sprite 0: %s", err)
+	}
+	if failed, err := checkSprites(context.Background(), bytes.NewReader(rom)); err != nil || failed != 0 {
+		t.Errorf("checkSprites = %d, %v, want 0 failed", failed, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+const (
+	swatchSize = 16
+	swatchGap  = 2
+)
+
+// digitFont is a 3x5 bitmap font for labelling images, one row per byte with the leftmost pixel in bit 2.
+var digitFont = [10][5]uint8{
+	{7, 5, 5, 5, 7},
+	{2, 6, 2, 2, 7},
+	{7, 1, 7, 4, 7},
+	{7, 1, 3, 1, 7},
+	{5, 5, 7, 1, 1},
+	{7, 4, 7, 1, 7},
+	{7, 4, 7, 5, 7},
+	{7, 1, 1, 1, 1},
+	{7, 5, 7, 5, 7},
+	{7, 5, 7, 1, 7},
+}
+
+func drawDigits(img draw.Image, at image.Point, n int, c color.Color) {
+	digits := []int{}
+	for {
+		digits = append([]int{n % 10}, digits...)
+		n /= 10
+		if n == 0 {
+			break
+		}
+	}
+
+	for i, d := range digits {
+		for y, row := range digitFont[d] {
+			for x := 0; x < 3; x++ {
+				if row&(4>>x) != 0 {
+					img.Set(at.X+i*4+x, at.Y+y, c)
+				}
+			}
+		}
+	}
+}
+
+// makeSwatches draws each palette as rows of 16 blocks, each labelled with its index in the palette. Colors are drawn opaque, including index 0, so the transparent color's stored value shows too.
+func makeSwatches(ps []color.Palette) *image.RGBA {
+	rows := 0
+	for _, p := range ps {
+		rows += (len(p) + 15) / 16
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 16*swatchSize, rows*swatchSize+(len(ps)-1)*swatchGap))
+
+	y := 0
+	for _, p := range ps {
+		for i, c := range p {
+			r, g, b, _ := c.RGBA()
+			opaque := color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+
+			x := (i % 16) * swatchSize
+			block := image.Rect(x, y, x+swatchSize, y+swatchSize)
+			draw.Draw(img, block, image.NewUniform(opaque), image.Point{}, draw.Src)
+
+			// Pick whichever of black or white stands out more against the swatch.
+			label := color.Color(color.White)
+			if 299*int(opaque.R)+587*int(opaque.G)+114*int(opaque.B) > 128000 {
+				label = color.Black
+			}
+			drawDigits(img, block.Min.Add(image.Point{2, 2}), i, label)
+
+			if i%16 == 15 {
+				y += swatchSize
+			}
+		}
+		if len(p)%16 != 0 {
+			y += swatchSize
+		}
+		y += swatchGap
+	}
+
+	return img
+}
+
+func writeSwatches(fn string, ps []color.Palette) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		return png.Encode(w, makeSwatches(ps))
+	})
+}
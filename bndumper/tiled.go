@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+type tiledProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value int    `xml:"value,attr"`
+}
+
+type tiledImage struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+}
+
+type tiledFrame struct {
+	TileID   int `xml:"tileid,attr"`
+	Duration int `xml:"duration,attr"`
+}
+
+type tiledAnimation struct {
+	Frames []tiledFrame `xml:"frame"`
+}
+
+type tiledTile struct {
+	ID         int             `xml:"id,attr"`
+	X          int             `xml:"x,attr"`
+	Y          int             `xml:"y,attr"`
+	Width      int             `xml:"width,attr"`
+	Height     int             `xml:"height,attr"`
+	Properties []tiledProperty `xml:"properties>property"`
+	Image      tiledImage      `xml:"image"`
+	Animation  *tiledAnimation `xml:"animation,omitempty"`
+}
+
+type tiledTileset struct {
+	XMLName    xml.Name    `xml:"tileset"`
+	Version    string      `xml:"version,attr"`
+	Name       string      `xml:"name,attr"`
+	TileWidth  int         `xml:"tilewidth,attr"`
+	TileHeight int         `xml:"tileheight,attr"`
+	TileCount  int         `xml:"tilecount,attr"`
+	Columns    int         `xml:"columns,attr"`
+	Tiles      []tiledTile `xml:"tile"`
+}
+
+// writeTiledTileset writes an image collection tileset where every frame is a tile cut out of the sprite sheet. The first tile of each animation also carries the whole animation, so Tiled can play it.
+func writeTiledTileset(outFn string, idx int, sheetSize image.Point, infos []frameInfo) error {
+	ts := tiledTileset{
+		Version:   "1.9",
+		Name:      fmt.Sprintf("%04d", idx),
+		TileCount: len(infos),
+	}
+
+	img := tiledImage{filepath.Base(spriteFilename(outFn, idx)), sheetSize.X, sheetSize.Y}
+
+	for i, info := range infos {
+		if info.BBox.Dx() > ts.TileWidth {
+			ts.TileWidth = info.BBox.Dx()
+		}
+		if info.BBox.Dy() > ts.TileHeight {
+			ts.TileHeight = info.BBox.Dy()
+		}
+
+		tile := tiledTile{
+			ID:     i,
+			X:      info.BBox.Min.X,
+			Y:      info.BBox.Min.Y,
+			Width:  info.BBox.Dx(),
+			Height: info.BBox.Dy(),
+			Properties: []tiledProperty{
+				{"delay", "int", info.Delay},
+				{"action", "int", int(fctrlAction(info.Action))},
+				{"origin_x", "int", info.Origin.X},
+				{"origin_y", "int", info.Origin.Y},
+			},
+			Image: img,
+		}
+
+		if i == 0 || infos[i-1].Anim != info.Anim {
+			tile.Animation = &tiledAnimation{}
+			for j := i; j < len(infos) && infos[j].Anim == info.Anim; j++ {
+				tile.Animation.Frames = append(tile.Animation.Frames, tiledFrame{j, int(sprites.TicksToDuration(infos[j].Delay).Milliseconds())})
+			}
+		}
+
+		ts.Tiles = append(ts.Tiles, tile)
+	}
+
+	return writeFileAtomic(fmt.Sprintf("%s/%04d.tsx", outFn, idx), func(w io.Writer) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+
+		enc := xml.NewEncoder(w)
+		enc.Indent("", " ")
+		if err := enc.Encode(ts); err != nil {
+			return err
+		}
+
+		_, err := io.WriteString(w, "\n")
+		return err
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// writeTimingCSV writes every frame's delay and action for -mode timing-csv, one row per frame, with cumulative counting the ticks up to the end of the frame within its animation.
+func writeTimingCSV(fn string, s []work) error {
+	return writeFileAtomic(fn, func(w io.Writer) error {
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"sprite", "anim", "frame", "delay", "action", "cumulative"}); err != nil {
+			return err
+		}
+
+		for _, wk := range s {
+			for animIdx, anim := range wk.anims {
+				cumulative := 0
+				for frameIdx, frame := range anim.Frames {
+					cumulative += int(frame.Delay)
+					if err := cw.Write([]string{
+						strconv.Itoa(wk.idx),
+						strconv.Itoa(animIdx),
+						strconv.Itoa(frameIdx),
+						strconv.Itoa(int(frame.Delay)),
+						strconv.Itoa(int(fctrlAction(frame.Action))),
+						strconv.Itoa(cumulative),
+					}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/murkland/bnrom/sprites"
+	"github.com/murkland/pngchunks"
+)
+
+// errInvalidOutput is returned by validateSheetFile when a sheet doesn't read back as it was written.
+var errInvalidOutput = errors.New("output failed -validate_output")
+
+// parseTextChunk splits a zTXt chunk as bndumper writes it, with a keyword and an 0xff compression method meaning none, returning the keyword and the data after it.
+func parseTextChunk(data []byte) (string, []byte, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || i+1 >= len(data) {
+		return "", nil, fmt.Errorf("text chunk has no keyword")
+	}
+	if data[i+1] != 0xff {
+		return "", nil, fmt.Errorf("text chunk %q has compression method %d, not 0xff", data[:i], data[i+1])
+	}
+	return string(data[:i]), data[i+2:], nil
+}
+
+// fctrlFrameInfoV1 is a frame of a version 1 fctrl chunk, before Delay and Action were widened.
+type fctrlFrameInfoV1 struct {
+	Left    int16
+	Top     int16
+	Right   int16
+	Bottom  int16
+	OriginX int16
+	OriginY int16
+	Delay   uint8
+	Action  uint8
+}
+
+// parseFctrl reads back the frames of an fctrl chunk, given the data after its keyword. Version 1 chunks have their actions turned back into FrameActions.
+func parseFctrl(data []byte) ([]fctrlFrameInfo, error) {
+	if len(data)%2 == 0 {
+		size := binary.Size(fctrlFrameInfoV1{})
+		if len(data)%size != 0 {
+			return nil, fmt.Errorf("version 1 fctrl chunk is %d bytes, not a multiple of %d", len(data), size)
+		}
+
+		old := make([]fctrlFrameInfoV1, len(data)/size)
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, old); err != nil {
+			return nil, fmt.Errorf("%w while reading fctrl chunk", err)
+		}
+
+		infos := make([]fctrlFrameInfo, len(old))
+		for i, o := range old {
+			action := sprites.FrameActionNext
+			switch o.Action {
+			case 1:
+				action = sprites.FrameActionLoop
+			case 2:
+				action = sprites.FrameActionStop
+			}
+			infos[i] = fctrlFrameInfo{o.Left, o.Top, o.Right, o.Bottom, o.OriginX, o.OriginY, uint16(o.Delay), action}
+		}
+		return infos, nil
+	}
+
+	if data[0] != fctrlVersion {
+		return nil, fmt.Errorf("fctrl chunk is version %d, not %d", data[0], fctrlVersion)
+	}
+	data = data[1:]
+
+	size := binary.Size(fctrlFrameInfo{})
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("fctrl chunk has %d bytes of frames, not a multiple of %d", len(data), size)
+	}
+
+	infos := make([]fctrlFrameInfo, len(data)/size)
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, infos); err != nil {
+		return nil, fmt.Errorf("%w while reading fctrl chunk", err)
+	}
+	return infos, nil
+}
+
+// fanimEntry is one animation range read back from an fanim chunk.
+type fanimEntry struct {
+	fanimRange
+	Name string
+}
+
+// parseFanim reads back the animation ranges of an fanim chunk, given the data after its keyword.
+func parseFanim(data []byte) ([]fanimEntry, error) {
+	if len(data) < 1 || data[0] != fanimVersion {
+		return nil, fmt.Errorf("fanim chunk isn't version %d", fanimVersion)
+	}
+
+	var entries []fanimEntry
+	r := bytes.NewReader(data[1:])
+	for r.Len() > 0 {
+		var e fanimEntry
+		if err := binary.Read(r, binary.LittleEndian, &e.fanimRange); err != nil {
+			return nil, fmt.Errorf("%w while reading fanim range %d", err, len(entries))
+		}
+		name := make([]byte, e.NameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, fmt.Errorf("%w while reading name of fanim range %d", err, len(entries))
+		}
+		e.Name = string(name)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readTextChunks returns the data of every zTXt chunk in the PNG in buf, by keyword.
+func readTextChunks(buf []byte) (map[string][]byte, error) {
+	pngr, err := pngchunks.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	texts := map[string][]byte{}
+	for {
+		chunk, err := pngr.NextChunk()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%w while reading png chunk", err)
+		}
+
+		if chunk.Type() == "zTXt" {
+			data, err := io.ReadAll(chunk)
+			if err != nil {
+				return nil, err
+			}
+			keyword, text, err := parseTextChunk(data)
+			if err != nil {
+				return nil, err
+			}
+			texts[keyword] = text
+		} else if _, err := io.Copy(io.Discard, chunk); err != nil {
+			return nil, err
+		}
+
+		if err := chunk.Close(); err != nil {
+			return nil, fmt.Errorf("%w in %s chunk", err, chunk.Type())
+		}
+	}
+	return texts, nil
+}
+
+// validateSheetFile reads back the sheet just written to fn, for -validate_output. It checks that the PNG decodes at the sheet's size and that the fctrl and fanim chunks hold what sheet says they should. Any mismatch is an errInvalidOutput.
+func validateSheetFile(fn string, sheet *spritesheet) error {
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", errInvalidOutput, fn, fmt.Sprintf(format, args...))
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return invalid("%s", err)
+	}
+	if got, want := img.Bounds().Size(), sheet.Image.Rect.Size(); got != want {
+		return invalid("decoded as %dx%d, not %dx%d", got.X, got.Y, want.X, want.Y)
+	}
+
+	texts, err := readTextChunks(buf)
+	if err != nil {
+		return invalid("%s", err)
+	}
+
+	fctrl, ok := texts["fctrl"]
+	if !ok {
+		return invalid("no fctrl chunk")
+	}
+	infos, err := parseFctrl(fctrl)
+	if err != nil {
+		return invalid("%s", err)
+	}
+	if len(infos) != len(sheet.Frames) {
+		return invalid("fctrl chunk has %d frames, not %d", len(infos), len(sheet.Frames))
+	}
+	for i, info := range sheet.Frames {
+		if infos[i] != makeFctrlFrameInfo(info) {
+			return invalid("fctrl chunk has %+v for frame %d, not %+v", infos[i], i, makeFctrlFrameInfo(info))
+		}
+	}
+
+	fanim, ok := texts["fanim"]
+	if !ok {
+		return invalid("no fanim chunk")
+	}
+	entries, err := parseFanim(fanim)
+	if err != nil {
+		return invalid("%s", err)
+	}
+	ranges := animRanges(sheet.Frames)
+	if len(entries) != len(ranges) {
+		return invalid("fanim chunk has %d animations, not %d", len(entries), len(ranges))
+	}
+	for i, ar := range ranges {
+		if e := entries[i]; int(e.Start) != ar.Start || int(e.Count) != ar.Count || e.Name != ar.Name {
+			return invalid("fanim chunk has frames %d+%d %q for animation %d, not %d+%d %q", e.Start, e.Count, e.Name, i, ar.Start, ar.Count, ar.Name)
+		}
+	}
+
+	return nil
+}
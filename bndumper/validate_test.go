@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+func TestFctrlRoundTrip(t *testing.T) {
+	infos := []frameInfo{
+		{BBox: image.Rect(0, 0, 8, 8), Delay: 300, Action: sprites.FrameActionLoop | 0x12},
+		{BBox: image.Rect(8, 0, 16, 8), Origin: image.Pt(-2, 3), Delay: 1, Action: 0x40},
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(fctrlVersion)
+	for _, info := range infos {
+		binary.Write(&buf, binary.LittleEndian, makeFctrlFrameInfo(info))
+	}
+
+	got, err := parseFctrl(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(infos) {
+		t.Fatalf("parsed %d frames, want %d", len(got), len(infos))
+	}
+	for i, info := range infos {
+		if want := makeFctrlFrameInfo(info); got[i] != want {
+			t.Errorf("frame %d = %+v, want %+v", i, got[i], want)
+		}
+	}
+	if got[0].Delay != 300 || got[0].Action != sprites.FrameActionLoop|0x12 {
+		t.Errorf("frame 0 has delay %d and action 0x%04x, want them untruncated", got[0].Delay, uint16(got[0].Action))
+	}
+}
+
+func TestParseFctrlVersion1(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, fctrlFrameInfoV1{0, 0, 8, 8, 4, 4, 5, 2})
+
+	got, err := parseFctrl(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (fctrlFrameInfo{0, 0, 8, 8, 4, 4, 5, sprites.FrameActionStop}); len(got) != 1 || got[0] != want {
+		t.Errorf("parsed %+v, want [%+v]", got, want)
+	}
+}
+
+func TestBuildSheetLongDelay(t *testing.T) {
+	tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+	tile.Pix[0] = 1
+	frame := sprites.Frame{
+		Palette:    color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}},
+		Delay:      1000,
+		Action:     sprites.FrameActionStop,
+		Tiles:      []*image.Paletted{tile},
+		OAMEntries: []sprites.OAMEntry{{WTiles: 1, HTiles: 1}},
+	}
+
+	sheet, err := buildSheet(0, []sprites.Animation{{Frames: []sprites.Frame{frame}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet == nil || len(sheet.Frames) != 1 || sheet.Frames[0].Delay != 1000 {
+		t.Fatalf("sheet frames = %+v, want one frame with delay 1000", sheet)
+	}
+}
+
+func TestSheetJSONValidates(t *testing.T) {
+	tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+	tile.Pix[9] = 1
+	frame := sprites.Frame{
+		Palette:    color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}},
+		Delay:      3,
+		Tiles:      []*image.Paletted{tile},
+		OAMEntries: []sprites.OAMEntry{{X: -4, Y: -8, WTiles: 1, HTiles: 1}},
+	}
+	last := frame
+	last.Action = sprites.FrameActionLoop
+	anims := []sprites.Animation{{Name: "walk", Frames: []sprites.Frame{frame, last}}, {Frames: []sprites.Frame{last}}}
+
+	sheet, err := buildSheet(4, anims, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := writeSheetJSON(dir, 4, sprites.Battle, []sprites.AtlasEnemy{{Enemy: 1, NameIndex: 2}}, sheet.Image.Rect.Size(), sheet.Frames, sheet.Grid); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(sheetJSONFilename(dir, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := sprites.ValidateMetadata(f); err != nil {
+		t.Errorf("written sidecar doesn't validate: %s", err)
+	}
+}
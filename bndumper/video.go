@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/murkland/bnrom/sprites"
+)
+
+// findFFmpeg looks up ffmpeg on PATH, which the video exporters need and nothing else does.
+func findFFmpeg() (string, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return "", fmt.Errorf("%w: the video exporters pipe frames to ffmpeg, so it has to be installed and on PATH, or use -export gif, which needs nothing else", err)
+	}
+	return path, nil
+}
+
+// videoExporter writes every animation of the sprite one after another as a video, anchored on their origins like the GIF exporter. The video runs at the GBA's refresh rate and repeats each frame for as many refreshes as it's shown, so the timing is exact rather than rounded to GIF's hundredths of a second.
+type videoExporter struct {
+	name string
+	// args are ffmpeg's output options, after the raw video input and before the output.
+	args []string
+}
+
+func (e videoExporter) Name() string { return e.name }
+
+func (e videoExporter) Export(w io.Writer, idx int, anims []sprites.Animation) error {
+	var all sprites.Animation
+	for _, anim := range anims {
+		all.Frames = append(all.Frames, anim.Frames...)
+	}
+
+	g, err := makeAlignedGIF(all)
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return errNothingToExport
+	}
+
+	path, err := findFFmpeg()
+	if err != nil {
+		return err
+	}
+
+	size := g.Image[0].Rect.Size()
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", size.X, size.Y),
+		"-framerate", strconv.FormatFloat(sprites.FrameRate, 'f', -1, 64),
+		"-i", "pipe:0",
+	}
+	args = append(args, e.args...)
+	args = append(args, "-f", e.name, "pipe:1")
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(path, args...)
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w while starting ffmpeg", err)
+	}
+
+	writeErr := func() error {
+		defer stdin.Close()
+
+		img := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		for i, frame := range all.Frames {
+			draw.Draw(img, img.Rect, g.Image[i], image.Point{}, draw.Src)
+
+			// A frame with no delay is still drawn once.
+			n := int(frame.Delay)
+			if n < 1 {
+				n = 1
+			}
+			for j := 0; j < n; j++ {
+				if _, err := stdin.Write(img.Pix); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}()
+
+	// If ffmpeg failed, writing to it fails too, but what it printed says why.
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%w while running ffmpeg: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if writeErr != nil {
+		return fmt.Errorf("%w while piping frames to ffmpeg", writeErr)
+	}
+	return nil
+}
+
+func init() {
+	// MP4 has no alpha channel, so transparent pixels come out black. H.264 needs even dimensions, and a fragmented MP4 can be written to a pipe.
+	registerExporter(videoExporter{"mp4", []string{
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p",
+		"-movflags", "frag_keyframe+empty_moov",
+	}})
+	registerExporter(videoExporter{"webm", []string{
+		"-c:v", "libvpx-vp9", "-pix_fmt", "yuva420p",
+	}})
+}
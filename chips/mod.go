@@ -1,13 +1,11 @@
 package chips
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
 	"io"
-	"log"
 	"os"
 
 	"github.com/murkland/bnrom/paletted"
@@ -22,18 +20,10 @@ type ROMInfo struct {
 }
 
 func mustDecodePalette(raw []uint8) color.Palette {
-	r := bytes.NewBuffer(raw)
-
-	var palette color.Palette
-	for i := 0; i < 16; i++ {
-		var c uint16
-		if err := binary.Read(r, binary.LittleEndian, &c); err != nil {
-			panic(err)
-		}
-
-		palette = append(palette, bgr555.ToRGBA(c))
+	palette, err := sprites.DecodePalette(raw, 16)
+	if err != nil {
+		panic(err)
 	}
-
 	return palette
 }
 
@@ -195,7 +185,7 @@ func ReadChipIcon(r io.ReadSeeker, ci ChipInfo) (*image.Paletted, error) {
 	}()
 
 	if _, err := r.Seek(int64(ci.ChipIconPtr & ^uint32(0x08000000)), os.SEEK_SET); err != nil {
-		log.Fatalf("%s", err)
+		return nil, fmt.Errorf("%w while seeking to chip icon pointer", err)
 	}
 
 	img := image.NewPaletted(image.Rect(0, 0, IconWidth, IconHeight), nil)
@@ -203,7 +193,7 @@ func ReadChipIcon(r io.ReadSeeker, ci ChipInfo) (*image.Paletted, error) {
 		for i := 0; i < 2; i++ {
 			tileImg, err := sprites.ReadTile(r, image.Rect(0, 0, 8, 8))
 			if err != nil {
-				log.Fatalf("%s", err)
+				return nil, fmt.Errorf("%w while reading icon tile (%d, %d)", err, i, j)
 			}
 
 			paletted.DrawOver(img, image.Rect(i*8, j*8, (i+1)*8, (j+1)*8), tileImg, image.Point{})
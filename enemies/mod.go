@@ -0,0 +1,96 @@
+package enemies
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+type EnemyEntry struct {
+	NameIndex   int
+	SpriteIndex int
+}
+
+// Layout is where the name index and the sprite index are in each entry of an enemy table, both as little-endian uint16s. The rest of the entry isn't read.
+type Layout struct {
+	EntrySize    int64
+	NameOffset   int64
+	SpriteOffset int64
+}
+
+// PackedLayout is an entry of just a name index followed by a sprite index. It's only a default for tables given by hand, not a layout read from any game.
+var PackedLayout = Layout{EntrySize: 4, NameOffset: 0, SpriteOffset: 2}
+
+func (l Layout) check() error {
+	if l.EntrySize < 2 {
+		return fmt.Errorf("enemy table entries of %d bytes can't hold a uint16", l.EntrySize)
+	}
+	if l.NameOffset < 0 || l.NameOffset+2 > l.EntrySize {
+		return fmt.Errorf("name index at %d isn't inside a %d byte enemy table entry", l.NameOffset, l.EntrySize)
+	}
+	if l.SpriteOffset < 0 || l.SpriteOffset+2 > l.EntrySize {
+		return fmt.Errorf("sprite index at %d isn't inside a %d byte enemy table entry", l.SpriteOffset, l.EntrySize)
+	}
+	return nil
+}
+
+type ROMInfo struct {
+	Offset int64
+	Count  int
+	Layout Layout
+
+	// Source is where the layout comes from, such as a disassembly or a memory map of the game, since it isn't the same across games and nothing in the table says what it is.
+	Source string
+}
+
+// KnownTables holds the enemy table of each game by ROM ID. It starts empty, as no game's table has been checked against its ROM yet, and the config file fills it in.
+var KnownTables = map[string]ROMInfo{}
+
+func FindROMInfo(romID string) *ROMInfo {
+	info, ok := KnownTables[romID]
+	if !ok {
+		return nil
+	}
+	return &info
+}
+
+// ReadEnemyTable reads count entries laid out as PackedLayout starting at offset.
+func ReadEnemyTable(r io.ReadSeeker, offset int64, count int) ([]EnemyEntry, error) {
+	return ReadTable(r, ROMInfo{Offset: offset, Count: count, Layout: PackedLayout})
+}
+
+// ReadTable reads the enemy table info describes.
+func ReadTable(r io.ReadSeeker, info ROMInfo) ([]EnemyEntry, error) {
+	if err := info.Layout.check(); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Seek(info.Offset, os.SEEK_SET); err != nil {
+		return nil, fmt.Errorf("%w while seeking to enemy table", err)
+	}
+
+	buf := make([]byte, info.Layout.EntrySize)
+	entries := make([]EnemyEntry, info.Count)
+	for i := 0; i < info.Count; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, fmt.Errorf("%w while reading enemy entry %d", err, i)
+		}
+
+		entries[i] = EnemyEntry{
+			int(binary.LittleEndian.Uint16(buf[info.Layout.NameOffset:])),
+			int(binary.LittleEndian.Uint16(buf[info.Layout.SpriteOffset:])),
+		}
+	}
+
+	return entries, nil
+}
+
+// BySprite groups enemy indexes by the sprite they use.
+func BySprite(entries []EnemyEntry) map[int][]int {
+	m := map[int][]int{}
+	for i, ent := range entries {
+		m[ent.SpriteIndex] = append(m[ent.SpriteIndex], i)
+	}
+	return m
+}
@@ -0,0 +1,40 @@
+package enemies
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestReadTableLayout(t *testing.T) {
+	// Two bytes before the table, then two 6 byte entries of two unread bytes, the sprite index and the name index.
+	data := []byte{
+		0xff, 0xff,
+		0xff, 0xff, 0x02, 0x00, 0x10, 0x00,
+		0xff, 0xff, 0x05, 0x01, 0x11, 0x00,
+	}
+
+	entries, err := ReadTable(bytes.NewReader(data), ROMInfo{
+		Offset: 2,
+		Count:  2,
+		Layout: Layout{EntrySize: 6, NameOffset: 4, SpriteOffset: 2},
+	})
+	if err != nil {
+		t.Fatalf("ReadTable: %s", err)
+	}
+
+	want := []EnemyEntry{{NameIndex: 0x10, SpriteIndex: 2}, {NameIndex: 0x11, SpriteIndex: 0x105}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %v, want %v", entries, want)
+	}
+}
+
+func TestReadTableBadLayout(t *testing.T) {
+	_, err := ReadTable(bytes.NewReader(make([]byte, 16)), ROMInfo{
+		Count:  1,
+		Layout: Layout{EntrySize: 4, NameOffset: 0, SpriteOffset: 3},
+	})
+	if err == nil {
+		t.Errorf("ReadTable with a sprite index past the end of the entry succeeded")
+	}
+}
@@ -52,6 +52,29 @@ func FlipVertical(img *image.Paletted) {
 }
 
 func FindTrim(img *image.Paletted) image.Rectangle {
+	return findTrim(img, func(p uint8) bool {
+		return p != 0
+	})
+}
+
+// FindTrimThreshold is like FindTrim, but only keeps pixels whose palette color has an alpha of at least minAlpha. Pixels outside the palette are kept unless they're index 0.
+func FindTrimThreshold(img *image.Paletted, minAlpha uint8) image.Rectangle {
+	var opaque [256]bool
+	for i := range opaque {
+		if i >= len(img.Palette) {
+			opaque[i] = i != 0
+			continue
+		}
+		_, _, _, a := img.Palette[i].RGBA()
+		opaque[i] = uint8(a>>8) >= minAlpha
+	}
+
+	return findTrim(img, func(p uint8) bool {
+		return opaque[p]
+	})
+}
+
+func findTrim(img *image.Paletted, opaque func(p uint8) bool) image.Rectangle {
 	left := img.Rect.Min.X
 	top := img.Rect.Min.Y
 	right := img.Rect.Max.X
@@ -59,7 +82,7 @@ func FindTrim(img *image.Paletted) image.Rectangle {
 
 	for left = img.Rect.Min.X; left < img.Rect.Max.X; left++ {
 		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
-			if img.Pix[y*img.Rect.Max.X+left] != 0 {
+			if opaque(img.Pix[y*img.Rect.Max.X+left]) {
 				goto leftDone
 			}
 		}
@@ -70,7 +93,7 @@ func FindTrim(img *image.Paletted) image.Rectangle {
 
 	for top = img.Rect.Min.Y; top < img.Rect.Max.Y; top++ {
 		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
-			if img.Pix[top*img.Rect.Max.X+x] != 0 {
+			if opaque(img.Pix[top*img.Rect.Max.X+x]) {
 				goto topDone
 			}
 		}
@@ -81,7 +104,7 @@ func FindTrim(img *image.Paletted) image.Rectangle {
 
 	for right = img.Rect.Max.X - 1; right >= img.Rect.Min.X; right-- {
 		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
-			if img.Pix[y*img.Rect.Max.X+right] != 0 {
+			if opaque(img.Pix[y*img.Rect.Max.X+right]) {
 				goto rightDone
 			}
 		}
@@ -93,7 +116,7 @@ func FindTrim(img *image.Paletted) image.Rectangle {
 
 	for bottom = img.Rect.Max.Y - 1; bottom >= img.Rect.Min.Y; bottom-- {
 		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
-			if img.Pix[bottom*img.Rect.Max.X+x] != 0 {
+			if opaque(img.Pix[bottom*img.Rect.Max.X+x]) {
 				goto bottomDone
 			}
 		}
@@ -0,0 +1,49 @@
+package palettes
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Colors come from BGR555, so each channel only has 32 levels. They're widened to 8 bits as c*255/31, rounding down, the same as everywhere else in bnrom, so the files round-trip exactly back to BGR555 with c*31/255 rounded to nearest.
+
+var ErrTooManyColors = errors.New("palettes: too many colors")
+
+func rgb(c color.Color) (uint8, uint8, uint8) {
+	rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return rgba.R, rgba.G, rgba.B
+}
+
+// WriteACT writes p as an Adobe Color Table: 256 RGB triplets followed by the number of colors and the index of the transparent color, which is 0 for GBA palettes.
+func WriteACT(w io.Writer, p color.Palette) error {
+	if len(p) > 256 {
+		return fmt.Errorf("%w: act files hold at most 256 colors, palette has %d", ErrTooManyColors, len(p))
+	}
+
+	var buf [256*3 + 4]byte
+	for i, c := range p {
+		buf[i*3], buf[i*3+1], buf[i*3+2] = rgb(c)
+	}
+	binary.BigEndian.PutUint16(buf[256*3:], uint16(len(p)))
+	binary.BigEndian.PutUint16(buf[256*3+2:], 0)
+
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// WriteJASC writes p as a JASC (Paint Shop Pro) palette.
+func WriteJASC(w io.Writer, p color.Palette) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "JASC-PAL\r\n0100\r\n%d\r\n", len(p))
+	for _, c := range p {
+		r, g, b := rgb(c)
+		fmt.Fprintf(bw, "%d %d %d\r\n", r, g, b)
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,81 @@
+package sprites
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrBadPointer        = errors.New("sprites: bad pointer")
+	ErrTruncated         = errors.New("sprites: truncated data")
+	ErrUnsupportedFormat = errors.New("sprites: unsupported format")
+	ErrOutOfRange        = errors.New("sprites: index out of range")
+
+	// ErrMissingColor isn't a decode error: it means the palette passed to MakeImageInto doesn't fit the frame, or the frame has no palette to draw with.
+	ErrMissingColor = errors.New("sprites: color missing from palette")
+	// ErrInvalidMetadata isn't a decode error either: it means a sidecar passed to ValidateMetadata doesn't conform.
+	ErrInvalidMetadata = errors.New("sprites: invalid metadata")
+)
+
+// ParseError records how far into the data being parsed a decode failure happened.
+type ParseError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset 0x%08x", e.Err, e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// PointerTableError is returned by PointerTable when sprite table entries point outside the ROM. It matches ErrBadPointer.
+type PointerTableError struct {
+	// Bad holds the indexes of the bad entries, in table order.
+	Bad []int
+}
+
+func (e *PointerTableError) Error() string {
+	return fmt.Sprintf("%s: %d sprite table entries point outside the ROM, the first being entry %d", ErrBadPointer, len(e.Bad), e.Bad[0])
+}
+
+func (e *PointerTableError) Is(target error) bool {
+	return target == ErrBadPointer
+}
+
+// decodeError tags an underlying error with one of the sentinel errors above, so that errors.Is matches both.
+type decodeError struct {
+	kind error
+	err  error
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *decodeError) Is(target error) bool {
+	return target == e.kind
+}
+
+func withKind(kind error, err error) error {
+	return &decodeError{kind, err}
+}
+
+func checkTruncated(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return withKind(ErrTruncated, err)
+	}
+	return err
+}
+
+// IsDecodeError reports whether err is one of the decode failures above, i.e. the sprite data is bad but the reader itself is fine.
+func IsDecodeError(err error) bool {
+	return errors.Is(err, ErrBadPointer) || errors.Is(err, ErrTruncated) || errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrOutOfRange)
+}
@@ -0,0 +1,138 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// minTableSprites is how many non-null entries a run of pointers needs before FindSpriteTable takes it for a sprite table.
+	minTableSprites = 4
+	// maxTableNulls is how many null entries in a row end a run, so that padding doesn't join a stray pointer-like word to the table after it.
+	maxTableNulls = 64
+)
+
+// plausibleSprite reports whether ptr, a raw sprite table entry, points into data at something that looks like the start of a sprite. It only looks at headers, so it's cheap enough to run on every word of a ROM.
+func plausibleSprite(data []byte, ptr uint32) bool {
+	if ptr&0x08000000 == 0 || ptr&^uint32(0x89FFFFFF) != 0 {
+		return false
+	}
+
+	off := int64(ptr &^ uint32(0x88000000))
+	if off+8 > int64(len(data)) {
+		return false
+	}
+
+	if ptr&0x80000000 != 0 {
+		// LZ77 data starts with 0x10 and the 24-bit decompressed size, which has to fit at least the header.
+		size := binary.LittleEndian.Uint32(data[off:]) >> 8
+		return data[off] == 0x10 && size >= 8
+	}
+
+	// The animation count comes after 3 bytes of per-sprite header, then the first animation pointer, relative to the sprite.
+	if data[off+3] == 0 {
+		return false
+	}
+	animPtr := int64(binary.LittleEndian.Uint32(data[off+4:]))
+	return off+4+animPtr < int64(len(data))
+}
+
+// FindSpriteTable guesses where the sprite table is in a ROM whose offset isn't known. It looks for the longest run of 4-byte entries that are either null or point at plausible sprite data, starting with a non-null one, and mostly increasing, as tables laid out in ROM order are. Entries can be in either PointerStyle; DetectPointerStyle says which once the table is found. It's a heuristic: any other table of pointers into sprite-like data, or a sprite table split in two, can fool it. Confirm the offset by dumping with it, e.g. with -check, before trusting it.
+func FindSpriteTable(data []byte) (int64, error) {
+	best, bestScore := findSpriteTable(data, GBAAbsolute)
+	// Small offsets are far more common in ROM data than words that look like GBA addresses, so ties go to GBAAbsolute.
+	if off, score := findSpriteTable(data, ROMRelative); score > bestScore {
+		best, bestScore = off, score
+	}
+
+	if best < 0 {
+		return 0, fmt.Errorf("%w: no run of at least %d plausible sprite pointers", ErrUnsupportedFormat, minTableSprites)
+	}
+	return best, nil
+}
+
+// findSpriteTable returns the start of the longest run FindSpriteTable would take for a table of entries in style, and how many non-null entries it has, or -1 if there's none.
+func findSpriteTable(data []byte, style PointerStyle) (int64, int) {
+	best, bestScore := int64(-1), 0
+
+	start, count, increasing, nulls := int64(-1), 0, 0, 0
+	var prev uint32
+	finish := func() {
+		if start < 0 || count < minTableSprites {
+			return
+		}
+		// Only a few entries being out of order is fine: tables sometimes point several entries at one sprite.
+		if increasing*2 < count-1 {
+			return
+		}
+		if count > bestScore {
+			best, bestScore = start, count
+		}
+	}
+
+	for off := int64(0); off+4 <= int64(len(data)); off += 4 {
+		ptr := binary.LittleEndian.Uint32(data[off:])
+
+		if ptr == 0 {
+			nulls++
+			if nulls > maxTableNulls {
+				finish()
+				start, count, increasing = -1, 0, 0
+			}
+			continue
+		}
+
+		if !plausibleSprite(data, style.address(ptr)) {
+			finish()
+			start, count, increasing = -1, 0, 0
+			continue
+		}
+		nulls = 0
+
+		if start < 0 {
+			start = off
+		} else if ptr&^uint32(0x80000000) > prev&^uint32(0x80000000) {
+			increasing++
+		}
+		count++
+		prev = ptr
+	}
+	finish()
+
+	return best, bestScore
+}
+
+// DetectPointerStyle works out which PointerStyle the sprite table described by ri is in, ignoring ri.PointerStyle, by which one has more of its entries point at plausible sprite data. It fails with ErrUnsupportedFormat if neither style makes any entry plausible.
+func DetectPointerStyle(data []byte, ri ROMInfo) (PointerStyle, error) {
+	if ri.Offset < 0 || ri.Offset > int64(len(data)) {
+		return 0, fmt.Errorf("%w: sprite table at 0x%08x", ErrOutOfRange, ri.Offset)
+	}
+
+	best, bestScore := GBAAbsolute, 0
+	for _, style := range []PointerStyle{GBAAbsolute, ROMRelative} {
+		ri.PointerStyle = style
+		r := bytes.NewReader(data[ri.Offset:])
+
+		score := 0
+		for i := 0; i < ri.Count; i++ {
+			ptr, err := ri.ReadPointer(r)
+			if err != nil {
+				return 0, fmt.Errorf("%w while reading sprite pointer %d", err, i)
+			}
+			if ptr != ri.PointerBase && plausibleSprite(data, ptr) {
+				score++
+			}
+		}
+
+		// Ties go to GBAAbsolute, which comes first.
+		if score > bestScore {
+			best, bestScore = style, score
+		}
+	}
+
+	if bestScore == 0 {
+		return 0, fmt.Errorf("%w: no sprite pointer in the table at 0x%08x is plausible in any pointer style", ErrUnsupportedFormat, ri.Offset)
+	}
+	return best, nil
+}
@@ -0,0 +1,92 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/murkland/bnrom/sprites/spritestest"
+)
+
+// relativeTable returns a copy of rom, as spritestest.ROM lays it out, with the first count table entries turned into ROMRelative ones.
+func relativeTable(rom []byte, count int) []byte {
+	rom = append([]byte(nil), rom...)
+	for i := 0; i < count; i++ {
+		if ptr := binary.LittleEndian.Uint32(rom[i*4:]); ptr != 0 {
+			binary.LittleEndian.PutUint32(rom[i*4:], ptr&^0x08000000)
+		}
+	}
+	return rom
+}
+
+func TestDetectPointerStyle(t *testing.T) {
+	absolute := spritestest.ROM([]int{0, 1, -1}, oneObjectSprite(-4, -4), oneObjectSprite(0, 0))
+	relative := relativeTable(absolute, 3)
+	// One entry of each style scores the same either way.
+	mixed := relativeTable(absolute, 1)
+
+	for _, tc := range []struct {
+		name string
+		rom  []byte
+		want PointerStyle
+	}{
+		{"absolute", absolute, GBAAbsolute},
+		{"relative", relative, ROMRelative},
+		{"tie goes to absolute", mixed, GBAAbsolute},
+	} {
+		// The style ri already has mustn't matter.
+		for _, given := range []PointerStyle{GBAAbsolute, ROMRelative} {
+			got, err := DetectPointerStyle(tc.rom, ROMInfo{Count: 3, PointerStyle: given})
+			if err != nil {
+				t.Errorf("%s: DetectPointerStyle: %s", tc.name, err)
+				continue
+			}
+			if got != tc.want {
+				t.Errorf("%s: DetectPointerStyle given %s = %s, want %s", tc.name, given, got, tc.want)
+			}
+		}
+	}
+
+	// Entries pointing past the end are plausible in neither style.
+	bogus := make([]byte, 16)
+	binary.LittleEndian.PutUint32(bogus, 0x08001000)
+	if _, err := DetectPointerStyle(bogus, ROMInfo{Count: 1}); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("DetectPointerStyle of a table with nothing plausible = %v, want ErrUnsupportedFormat", err)
+	}
+	if _, err := DetectPointerStyle(absolute, ROMInfo{Offset: int64(len(absolute)) + 4, Count: 1}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("DetectPointerStyle past the end = %v, want ErrOutOfRange", err)
+	}
+}
+
+func TestReadROMRelative(t *testing.T) {
+	absolute := spritestest.ROM([]int{0, 1, -1}, oneObjectSprite(-4, -4), oneObjectSprite(0, 0))
+	relative := relativeTable(absolute, 3)
+
+	want, err := Read(bytes.NewReader(absolute), int64(len(absolute)), ROMInfo{Count: 3})
+	if err != nil {
+		t.Fatalf("Read of the absolute table: %s", err)
+	}
+	got, err := Read(bytes.NewReader(relative), int64(len(relative)), ROMInfo{Count: 3, PointerStyle: ROMRelative})
+	if err != nil {
+		t.Fatalf("Read of the relative table: %s", err)
+	}
+	if got[0] == nil || got[1] == nil || got[2] != nil {
+		t.Fatalf("Read of the relative table decoded %t, %t, %t, want the two sprites and the null entry", got[0] != nil, got[1] != nil, got[2] != nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sprites read through a ROMRelative table differ from the same sprites through a GBAAbsolute one")
+	}
+
+	// Read in the wrong style, none of the entries are ROM pointers.
+	wrong, err := Read(bytes.NewReader(relative), int64(len(relative)), ROMInfo{Count: 3})
+	if err != nil {
+		t.Fatalf("Read of the relative table as absolute: %s", err)
+	}
+	for i, anims := range wrong {
+		if anims != nil {
+			t.Errorf("sprite %d decoded from a relative table read as absolute", i)
+		}
+	}
+}
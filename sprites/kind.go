@@ -0,0 +1,56 @@
+package sprites
+
+import "fmt"
+
+// Kind is what a sprite is used for. BN draws every kind from the same tile and OAM format, so nothing in the sprite data says which it is: it comes from ROMInfo.Kinds instead.
+type Kind int
+
+const (
+	// UnknownKind is the kind of sprites no ROMInfo.Kinds range covers, which is all of them in the built-in tables.
+	UnknownKind Kind = iota
+	Battle
+	Overworld
+	Effect
+)
+
+var kindNames = []string{"unknown", "battle", "overworld", "effect"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// UnmarshalText parses a kind by the name String gives it.
+func (k *Kind) UnmarshalText(b []byte) error {
+	for i, name := range kindNames {
+		if string(b) == name {
+			*k = Kind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sprite kind %q", b)
+}
+
+// KindRange says that Count sprites from sprite Start are of one kind.
+type KindRange struct {
+	Start int
+	Count int
+	Kind  Kind
+}
+
+// SpriteKind returns the kind of sprite i, from the first of ri.Kinds that covers it.
+func (ri ROMInfo) SpriteKind(i int) Kind {
+	for _, kr := range ri.Kinds {
+		if i >= kr.Start && i < kr.Start+kr.Count {
+			return kr.Kind
+		}
+	}
+	return UnknownKind
+}
+
+// Kind returns the kind of sprite the animation was read from, or UnknownKind if it wasn't read through a ROMInfo that knows.
+func (a Animation) Kind() Kind {
+	return a.kind
+}
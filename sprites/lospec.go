@@ -0,0 +1,77 @@
+package sprites
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// lospecJSON is the subset of Lospec's JSON palette format that matters here.
+type lospecJSON struct {
+	Name   string   `json:"name"`
+	Colors []string `json:"colors"`
+}
+
+func parseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.NRGBA{}, fmt.Errorf("%w: color %q isn't RRGGBB", ErrUnsupportedFormat, s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%w: color %q isn't RRGGBB", ErrUnsupportedFormat, s)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
+
+// LoadLospec reads a palette in either of Lospec's formats: a .hex file of one RRGGBB color per line, or the JSON export with a "colors" array. Every color is opaque.
+func LoadLospec(r io.Reader) (color.Palette, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var hexes []string
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+		var doc lospecJSON
+		if err := json.Unmarshal(trimmed, &doc); err != nil {
+			return nil, fmt.Errorf("%w while parsing lospec json", withKind(ErrUnsupportedFormat, err))
+		}
+		hexes = doc.Colors
+	} else {
+		sc := bufio.NewScanner(bytes.NewReader(raw))
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" {
+				hexes = append(hexes, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	p := make(color.Palette, len(hexes))
+	for i, h := range hexes {
+		c, err := parseHexColor(h)
+		if err != nil {
+			return nil, fmt.Errorf("%w while reading color %d", err, i)
+		}
+		p[i] = c
+	}
+	return p, nil
+}
+
+// WriteLospec writes p as a Lospec .hex file. There's no alpha in the format, so transparent entries are written as their color like any other.
+func WriteLospec(w io.Writer, p color.Palette) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range p {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%02x%02x%02x\n", nc.R, nc.G, nc.B)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,57 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLospecRoundTrip(t *testing.T) {
+	p := color.Palette{
+		color.NRGBA{0x00, 0x00, 0x00, 0xff},
+		color.NRGBA{0xff, 0x80, 0x01, 0xff},
+		color.NRGBA{0x12, 0x34, 0x56, 0xff},
+		color.NRGBA{0xfe, 0xdc, 0xba, 0xff},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLospec(&buf, p); err != nil {
+		t.Fatalf("WriteLospec: %s", err)
+	}
+	if want := "000000\nff8001\n123456\nfedcba\n"; buf.String() != want {
+		t.Errorf("WriteLospec wrote %q, want %q", buf.String(), want)
+	}
+
+	back, err := LoadLospec(&buf)
+	if err != nil {
+		t.Fatalf("LoadLospec: %s", err)
+	}
+	if !reflect.DeepEqual(back, p) {
+		t.Errorf("palette didn't survive a round trip: got %v, want %v", back, p)
+	}
+
+	// The JSON export reads the same colors, with or without #.
+	back, err = LoadLospec(strings.NewReader(`{"name": "test", "colors": ["000000", "#ff8001", "123456", "FEDCBA"]}`))
+	if err != nil {
+		t.Fatalf("LoadLospec of JSON: %s", err)
+	}
+	if !reflect.DeepEqual(back, p) {
+		t.Errorf("JSON palette = %v, want %v", back, p)
+	}
+
+	// Transparent entries lose their alpha, since the format has none.
+	buf.Reset()
+	if err := WriteLospec(&buf, color.Palette{color.RGBA{}}); err != nil {
+		t.Fatal(err)
+	}
+	if back, err := LoadLospec(&buf); err != nil || back[0] != (color.NRGBA{0, 0, 0, 0xff}) {
+		t.Errorf("transparent entry came back as %v, %v, want opaque black", back, err)
+	}
+
+	if _, err := LoadLospec(strings.NewReader("000000\nfff\n")); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("LoadLospec of a short color = %v, want ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,242 @@
+package sprites
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// AtlasFrame is one frame of an AtlasMetadata sheet: where it is, where its origin is relative to that, and and its delay in refreshes and its action as the JSON encodes it: 0 for next, 1 for loop and 2 for stop, with any other bits of the FrameAction in Event.
+type AtlasFrame struct {
+	Anim    int `json:"anim"`
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	W       int `json:"w"`
+	H       int `json:"h"`
+	OriginX int `json:"origin_x"`
+	OriginY int `json:"origin_y"`
+	Delay   int `json:"delay"`
+	Action  int `json:"action"`
+	Event   int `json:"event,omitempty"`
+}
+
+// AtlasEvent is one entry of an animation's event track, as Animation.Events gives it. Frame counts from the start of the animation.
+type AtlasEvent struct {
+	Frame  int `json:"frame"`
+	Action int `json:"action"`
+	Event  int `json:"event,omitempty"`
+}
+
+// AtlasAnimation is a run of consecutive frames of one animation.
+type AtlasAnimation struct {
+	Start  int          `json:"start"`
+	Count  int          `json:"count"`
+	Name   string       `json:"name,omitempty"`
+	Events []AtlasEvent `json:"events,omitempty"`
+}
+
+// AtlasGrid is the cell layout of a sheet laid out with one row per animation.
+type AtlasGrid struct {
+	Cols  int `json:"cols"`
+	Rows  int `json:"rows"`
+	CellW int `json:"cell_w"`
+	CellH int `json:"cell_h"`
+}
+
+// AtlasEnemy is an entry of the game's enemy table that uses the sheet's sprite.
+type AtlasEnemy struct {
+	Enemy     int `json:"enemy"`
+	NameIndex int `json:"name_index"`
+}
+
+// AtlasMetadata is the JSON sidecar written next to each sprite sheet. AtlasMetadataSchema describes it for tools in other languages.
+type AtlasMetadata struct {
+	Sprite     int              `json:"sprite"`
+	Image      string           `json:"image"`
+	Width      int              `json:"width"`
+	Height     int              `json:"height"`
+	Frames     []AtlasFrame     `json:"frames"`
+	Animations []AtlasAnimation `json:"animations"`
+	Grid       *AtlasGrid       `json:"grid,omitempty"`
+
+	// Kind is the sprite's Kind, left out if it's UnknownKind.
+	Kind string `json:"kind,omitempty"`
+
+	// Enemies lists the enemies that use the sprite, when the game's enemy table is known.
+	Enemies []AtlasEnemy `json:"enemies,omitempty"`
+}
+
+// AtlasMetadataSchema is the JSON schema of AtlasMetadata. ValidateMetadata checks everything it does, and also the constraints between fields it can't express.
+//
+//go:embed atlas_metadata.schema.json
+var AtlasMetadataSchema []byte
+
+// requireFields checks that the JSON object raw has every one of fields, none of them null.
+func requireFields(raw json.RawMessage, what string, fields ...string) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidMetadata, what, err)
+	}
+	if m == nil {
+		return fmt.Errorf("%w: %s is null", ErrInvalidMetadata, what)
+	}
+
+	for _, f := range fields {
+		v, ok := m[f]
+		if !ok {
+			return fmt.Errorf("%w: %s has no %s", ErrInvalidMetadata, what, f)
+		}
+		if bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+			return fmt.Errorf("%w: %s has a null %s", ErrInvalidMetadata, what, f)
+		}
+	}
+	return nil
+}
+
+// checkAction checks an action and event as the JSON encodes them: the action is 0 for next, 1 for loop and 2 for stop, rather than the raw FrameAction the fctrl chunk stores.
+func checkAction(what string, action, event int) error {
+	if action < 0 || action > 2 {
+		return fmt.Errorf("%w: %s has action %d, which isn't 0, 1 or 2", ErrInvalidMetadata, what, action)
+	}
+	if event < 0 || event > math.MaxUint16 {
+		return fmt.Errorf("%w: %s has event %d, which doesn't fit in 16 bits", ErrInvalidMetadata, what, event)
+	}
+	return nil
+}
+
+// ValidateMetadata checks that r holds an AtlasMetadata sidecar that conforms to AtlasMetadataSchema, and that its frames fit in the sheet and its animations cover them in order. It fails with ErrInvalidMetadata, or the reader's own error.
+func ValidateMetadata(r io.Reader) error {
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+
+	var meta AtlasMetadata
+	if err := dec.Decode(&meta); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w: trailing data after metadata", ErrInvalidMetadata)
+	}
+
+	var raw struct {
+		Frames     []json.RawMessage `json:"frames"`
+		Animations []json.RawMessage `json:"animations"`
+		Grid       json.RawMessage   `json:"grid"`
+		Enemies    []json.RawMessage `json:"enemies"`
+	}
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidMetadata, err)
+	}
+
+	if err := requireFields(buf, "metadata", "sprite", "image", "width", "height", "frames", "animations"); err != nil {
+		return err
+	}
+	if meta.Sprite < 0 {
+		return fmt.Errorf("%w: sprite %d is negative", ErrInvalidMetadata, meta.Sprite)
+	}
+	if meta.Kind != "" {
+		var k Kind
+		if err := k.UnmarshalText([]byte(meta.Kind)); err != nil || k == UnknownKind {
+			return fmt.Errorf("%w: kind %q isn't battle, overworld or effect", ErrInvalidMetadata, meta.Kind)
+		}
+	}
+	for i, enemy := range meta.Enemies {
+		what := fmt.Sprintf("enemy %d", i)
+		if err := requireFields(raw.Enemies[i], what, "enemy", "name_index"); err != nil {
+			return err
+		}
+		if enemy.Enemy < 0 {
+			return fmt.Errorf("%w: %s has negative enemy %d", ErrInvalidMetadata, what, enemy.Enemy)
+		}
+		if enemy.NameIndex < 0 || enemy.NameIndex > math.MaxUint16 {
+			return fmt.Errorf("%w: %s has name index %d, which doesn't fit in 16 bits", ErrInvalidMetadata, what, enemy.NameIndex)
+		}
+	}
+	if meta.Image == "" {
+		return fmt.Errorf("%w: image is empty", ErrInvalidMetadata)
+	}
+	if meta.Width < 0 || meta.Height < 0 {
+		return fmt.Errorf("%w: sheet size %dx%d is negative", ErrInvalidMetadata, meta.Width, meta.Height)
+	}
+
+	for i, frame := range meta.Frames {
+		what := fmt.Sprintf("frame %d", i)
+		if err := requireFields(raw.Frames[i], what, "anim", "x", "y", "w", "h", "origin_x", "origin_y", "delay", "action"); err != nil {
+			return err
+		}
+		if frame.Anim < 0 {
+			return fmt.Errorf("%w: %s has negative anim %d", ErrInvalidMetadata, what, frame.Anim)
+		}
+		if frame.X < 0 || frame.Y < 0 || frame.W < 0 || frame.H < 0 || frame.X+frame.W > meta.Width || frame.Y+frame.H > meta.Height {
+			return fmt.Errorf("%w: %s at %d,%d size %dx%d isn't inside the %dx%d sheet", ErrInvalidMetadata, what, frame.X, frame.Y, frame.W, frame.H, meta.Width, meta.Height)
+		}
+		if frame.Delay < 0 || frame.Delay > math.MaxUint16 {
+			return fmt.Errorf("%w: %s has delay %d, which doesn't fit in 16 bits", ErrInvalidMetadata, what, frame.Delay)
+		}
+		if err := checkAction(what, frame.Action, frame.Event); err != nil {
+			return err
+		}
+	}
+
+	next := 0
+	for i, anim := range meta.Animations {
+		what := fmt.Sprintf("animation %d", i)
+		if err := requireFields(raw.Animations[i], what, "start", "count"); err != nil {
+			return err
+		}
+		if anim.Start != next {
+			return fmt.Errorf("%w: %s starts at frame %d, not %d where the one before it ends", ErrInvalidMetadata, what, anim.Start, next)
+		}
+		if anim.Count < 1 || anim.Start+anim.Count > len(meta.Frames) {
+			return fmt.Errorf("%w: %s has %d frames from frame %d, but there are %d frames", ErrInvalidMetadata, what, anim.Count, anim.Start, len(meta.Frames))
+		}
+		for j := anim.Start + 1; j < anim.Start+anim.Count; j++ {
+			if meta.Frames[j].Anim != meta.Frames[anim.Start].Anim {
+				return fmt.Errorf("%w: %s covers frame %d, which is in a different anim", ErrInvalidMetadata, what, j)
+			}
+		}
+
+		var rawAnim struct {
+			Events []json.RawMessage `json:"events"`
+		}
+		if err := json.Unmarshal(raw.Animations[i], &rawAnim); err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidMetadata, what, err)
+		}
+		for j, ev := range anim.Events {
+			evWhat := fmt.Sprintf("%s event %d", what, j)
+			if err := requireFields(rawAnim.Events[j], evWhat, "frame", "action"); err != nil {
+				return err
+			}
+			if ev.Frame < 0 || ev.Frame >= anim.Count {
+				return fmt.Errorf("%w: %s is on frame %d of %d", ErrInvalidMetadata, evWhat, ev.Frame, anim.Count)
+			}
+			if err := checkAction(evWhat, ev.Action, ev.Event); err != nil {
+				return err
+			}
+		}
+
+		next += anim.Count
+	}
+	if next != len(meta.Frames) {
+		return fmt.Errorf("%w: animations cover %d of %d frames", ErrInvalidMetadata, next, len(meta.Frames))
+	}
+
+	if raw.Grid != nil {
+		if err := requireFields(raw.Grid, "grid", "cols", "rows", "cell_w", "cell_h"); err != nil {
+			return err
+		}
+		g := meta.Grid
+		if g.Cols < 0 || g.Rows < 0 || g.CellW < 0 || g.CellH < 0 {
+			return fmt.Errorf("%w: grid %dx%d of %dx%d cells is negative", ErrInvalidMetadata, g.Cols, g.Rows, g.CellW, g.CellH)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testMetadata() AtlasMetadata {
+	return AtlasMetadata{
+		Sprite: 3,
+		Image:  "0003.png",
+		Width:  32,
+		Height: 16,
+		Frames: []AtlasFrame{
+			{Anim: 0, X: 0, Y: 0, W: 16, H: 16, OriginX: 8, OriginY: 16, Delay: 4},
+			{Anim: 0, X: 16, Y: 0, W: 8, H: 8, OriginX: -2, OriginY: 20, Delay: 65535, Action: 1, Event: 7},
+		},
+		Animations: []AtlasAnimation{
+			{Start: 0, Count: 2, Name: "idle", Events: []AtlasEvent{{Frame: 1, Action: 1, Event: 7}}},
+		},
+		Grid:    &AtlasGrid{Cols: 2, Rows: 1, CellW: 16, CellH: 16},
+		Kind:    "battle",
+		Enemies: []AtlasEnemy{{Enemy: 5, NameIndex: 12}},
+	}
+}
+
+func TestValidateMetadataRoundTrip(t *testing.T) {
+	buf, err := json.Marshal(testMetadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateMetadata(bytes.NewReader(buf)); err != nil {
+		t.Fatalf("ValidateMetadata: %s", err)
+	}
+
+	var back AtlasMetadata
+	if err := json.Unmarshal(buf, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, testMetadata()) {
+		t.Errorf("metadata didn't survive a round trip: got %+v", back)
+	}
+
+	for _, bad := range []func(m *AtlasMetadata){
+		func(m *AtlasMetadata) { m.Frames[1].X = 30 },
+		func(m *AtlasMetadata) { m.Frames[0].Delay = 65536 },
+		func(m *AtlasMetadata) { m.Frames[0].Action = 3 },
+		func(m *AtlasMetadata) { m.Animations[0].Count = 1 },
+		func(m *AtlasMetadata) { m.Animations[0].Events[0].Frame = 2 },
+		func(m *AtlasMetadata) { m.Kind = "boss" },
+		func(m *AtlasMetadata) { m.Enemies[0].NameIndex = -1 },
+	} {
+		m := testMetadata()
+		bad(&m)
+		buf, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateMetadata(bytes.NewReader(buf)); !errors.Is(err, ErrInvalidMetadata) {
+			t.Errorf("ValidateMetadata(%s) = %v, want ErrInvalidMetadata", buf, err)
+		}
+	}
+}
+
+// schemaObject is the part of a JSON schema object definition the test compares against the Go types.
+type schemaObject struct {
+	Required   []string                   `json:"required"`
+	Properties map[string]json.RawMessage `json:"properties"`
+	Items      *schemaObject              `json:"items"`
+	Ref        string                     `json:"$ref"`
+	Defs       map[string]*schemaObject   `json:"$defs"`
+}
+
+// jsonFields returns the JSON names of t's fields, and which of them aren't omitempty.
+func jsonFields(t reflect.Type) (all, required []string) {
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		all = append(all, name)
+		if opts != "omitempty" {
+			required = append(required, name)
+		}
+	}
+	return all, required
+}
+
+func TestSchemaMatchesMetadata(t *testing.T) {
+	var root schemaObject
+	if err := json.Unmarshal(AtlasMetadataSchema, &root); err != nil {
+		t.Fatalf("schema isn't valid JSON: %s", err)
+	}
+
+	// resolve follows $ref and array items down to the object a property holds.
+	var resolve func(raw json.RawMessage) *schemaObject
+	resolve = func(raw json.RawMessage) *schemaObject {
+		var obj schemaObject
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			switch {
+			case obj.Ref != "":
+				obj = *root.Defs[strings.TrimPrefix(obj.Ref, "#/$defs/")]
+			case obj.Items != nil:
+				obj = *obj.Items
+			default:
+				return &obj
+			}
+		}
+	}
+
+	var check func(what string, obj *schemaObject, typ reflect.Type)
+	check = func(what string, obj *schemaObject, typ reflect.Type) {
+		all, required := jsonFields(typ)
+
+		var props []string
+		for p := range obj.Properties {
+			props = append(props, p)
+		}
+		sort.Strings(props)
+		sort.Strings(all)
+		if !reflect.DeepEqual(props, all) {
+			t.Errorf("%s: schema has properties %v, but %s has fields %v", what, props, typ.Name(), all)
+		}
+
+		req := append([]string(nil), obj.Required...)
+		sort.Strings(req)
+		sort.Strings(required)
+		if !reflect.DeepEqual(req, required) {
+			t.Errorf("%s: schema requires %v, but %s always has %v", what, req, typ.Name(), required)
+		}
+
+		for i := 0; i < typ.NumField(); i++ {
+			ft := typ.Field(i).Type
+			for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				continue
+			}
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			check(what+"."+name, resolve(obj.Properties[name]), ft)
+		}
+	}
+	check("metadata", &root, reflect.TypeOf(AtlasMetadata{}))
+}
@@ -0,0 +1,33 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package sprites
+
+import (
+	"io"
+	"os"
+)
+
+// sizedFile is an open file that knows its size, like the reader OpenMmap returns where it can map files.
+type sizedFile struct {
+	*os.File
+	size int64
+}
+
+func (f sizedFile) Size() int64 {
+	return f.size
+}
+
+// OpenMmap falls back to opening the file normally on this platform, since there's no mmap to use.
+func OpenMmap(path string) (io.ReaderAt, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return sizedFile{f, fi.Size()}, f.Close, nil
+}
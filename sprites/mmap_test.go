@@ -0,0 +1,97 @@
+package sprites
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/murkland/bnrom/sprites/spritestest"
+)
+
+// writeBenchROM writes a ROM of count sprites, each of a 4 frame animation, to a file in dir.
+func writeBenchROM(tb testing.TB, dir string, count int) (string, ROMInfo) {
+	tb.Helper()
+
+	var frames []spritestest.Frame
+	for i := 0; i < 4; i++ {
+		frames = append(frames, spritestest.Frame{Objects: []spritestest.Object{{Fill: uint8(i + 1), X: -8, Y: -8}, {Fill: 5, X: 0, Y: -8}}, Delay: 2})
+	}
+	frames[len(frames)-1].Action = uint16(FrameActionLoop)
+
+	table := make([]int, count)
+	sprites := make([][]byte, count)
+	for i := range table {
+		table[i] = i
+		sprites[i] = spritestest.Sprite(frames)
+	}
+
+	fn := filepath.Join(dir, "rom.gba")
+	if err := os.WriteFile(fn, spritestest.ROM(table, sprites...), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return fn, ROMInfo{Count: count}
+}
+
+func TestOpenMmapMatchesReadFile(t *testing.T) {
+	fn, ri := writeBenchROM(t, t.TempDir(), 8)
+
+	r, closeMmap, err := OpenMmap(fn)
+	if err != nil {
+		t.Fatalf("OpenMmap: %s", err)
+	}
+	defer closeMmap()
+	size := r.(interface{ Size() int64 }).Size()
+
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("OpenMmap size = %d, want %d", size, len(data))
+	}
+
+	got, err := Read(r, size, ri)
+	if err != nil {
+		t.Fatalf("Read over mmap: %s", err)
+	}
+	want, err := Read(bytes.NewReader(data), int64(len(data)), ri)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sprites read through OpenMmap differ from ones read from the file's bytes")
+	}
+}
+
+func BenchmarkReadMmap(b *testing.B) {
+	fn, ri := writeBenchROM(b, b.TempDir(), 256)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		r, closeMmap, err := OpenMmap(fn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Read(r, r.(interface{ Size() int64 }).Size(), ri); err != nil {
+			b.Fatal(err)
+		}
+		closeMmap()
+	}
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	fn, ri := writeBenchROM(b, b.TempDir(), 256)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Read(bytes.NewReader(data), int64(len(data)), ri); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
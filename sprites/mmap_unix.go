@@ -0,0 +1,42 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package sprites
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// OpenMmap maps the file at path into memory read-only, so Read and NewReaderAt page the ROM in as it's read instead of copying all of it up front. The returned ReaderAt also has a Size method giving the file's size, which they need alongside it. Call the returned function to unmap it once nothing reads from it anymore. Only these Unix platforms map files; elsewhere, the file is just opened and read normally. The file mustn't shrink while it's mapped, as reading past its new end crashes the program instead of failing.
+func OpenMmap(path string) (io.ReaderAt, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return bytes.NewReader(nil), func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return nil, nil, fmt.Errorf("%s is too big to map", path)
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w while mapping %s", err, path)
+	}
+
+	return bytes.NewReader(data), func() error {
+		return syscall.Munmap(data)
+	}, nil
+}
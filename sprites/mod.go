@@ -7,63 +7,142 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"io"
+	"math"
 	"os"
+	"time"
 
 	"github.com/murkland/bnrom/paletted"
 	"github.com/murkland/gbarom/bgr555"
 	"github.com/murkland/gbarom/lz77"
 )
 
+// PointerStyle is how a sprite table's entries say where each sprite is.
+type PointerStyle int
+
+const (
+	// GBAAbsolute entries are full GBA addresses, with the ROM at 0x08000000.
+	GBAAbsolute PointerStyle = iota
+	// ROMRelative entries are offsets from the start of the ROM, still with the high bit set for compressed sprites.
+	ROMRelative
+)
+
+func (s PointerStyle) String() string {
+	switch s {
+	case GBAAbsolute:
+		return "gba_absolute"
+	case ROMRelative:
+		return "rom_relative"
+	}
+	return fmt.Sprintf("PointerStyle(%d)", int(s))
+}
+
+// UnmarshalText parses a pointer style by the name String gives it.
+func (s *PointerStyle) UnmarshalText(b []byte) error {
+	for _, style := range []PointerStyle{GBAAbsolute, ROMRelative} {
+		if string(b) == style.String() {
+			*s = style
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown pointer style %q", b)
+}
+
+// address turns a table entry, after PointerBase, into the GBA address ReadNext follows. Null entries stay null.
+func (s PointerStyle) address(ptr uint32) uint32 {
+	if s == ROMRelative && ptr != 0 {
+		return ptr + 0x08000000
+	}
+	return ptr
+}
+
 type ROMInfo struct {
 	Offset int64
 	Count  int
+
+	// PointerBytes is the size of each sprite table entry, from 1 to 4. Zero means 4, a full GBA address.
+	PointerBytes int
+	// PointerBase is added to every table entry, for tables whose entries are offsets or are missing the high byte of the address.
+	PointerBase uint32
+	// PointerStyle is how entries are turned into addresses once PointerBase is added. DetectPointerStyle can work it out for a new table.
+	PointerStyle PointerStyle
+
+	// Kinds gives the kind of each range of sprites, for Animation.Kind. Sprites it doesn't cover are UnknownKind.
+	Kinds []KindRange
+
+	// Decode is what sprites in the table are checked against as they're decoded.
+	Decode DecodeOptions
+	// Adjust is applied to the palette of every frame ReadNext decodes.
+	Adjust ColorAdjust
+}
+
+// EntrySize returns the size of one sprite table entry in bytes.
+func (ri ROMInfo) EntrySize() int64 {
+	if ri.PointerBytes == 0 {
+		return 4
+	}
+	return int64(ri.PointerBytes)
+}
+
+// ReadPointer reads one sprite table entry from r and turns it into a sprite pointer, as ReadNext expects to find in a table of full GBA addresses. Null entries come out as PointerBase, whatever the style.
+func (ri ROMInfo) ReadPointer(r io.Reader) (uint32, error) {
+	n := ri.EntrySize()
+	if n < 1 || n > 4 {
+		return 0, fmt.Errorf("%w: %d byte sprite pointers", ErrUnsupportedFormat, n)
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:n]); err != nil {
+		return 0, fmt.Errorf("%w while reading sprite pointer", checkTruncated(err))
+	}
+
+	raw := binary.LittleEndian.Uint32(buf[:])
+	if raw == 0 {
+		return ri.PointerBase, nil
+	}
+	return ri.PointerStyle.address(ri.PointerBase + raw), nil
+}
+
+type GameInfo struct {
+	Title string
+	ROMInfo
+}
+
+// KnownGames holds the sprite table of each supported game by ROM ID. None of them gives Kinds: which ranges of each table are battle, overworld or effect sprites hasn't been mapped out for any game, so every sprite is UnknownKind unless the config file labels it.
+var KnownGames = map[string]GameInfo{
+	"BR6E": {"Mega Man Battle Network 6: Cybeast Falzar (US)", ROMInfo{Offset: 0x00031CEC, Count: 815}},
+	"BR6P": {"Mega Man Battle Network 6: Cybeast Falzar (EU)", ROMInfo{Offset: 0x00031CEC, Count: 815}},
+	"BR5E": {"Mega Man Battle Network 6: Cybeast Gregar (US)", ROMInfo{Offset: 0x00031CEC, Count: 815}},
+	"BR5P": {"Mega Man Battle Network 6: Cybeast Gregar (EU)", ROMInfo{Offset: 0x00031CEC, Count: 815}},
+	"BR6J": {"Rockman EXE 6: Dennoutoushi Falzar (JP)", ROMInfo{Offset: 0x00032CA8, Count: 815}},
+	"BR5J": {"Rockman EXE 6: Dennoutoushi Gregar (JP)", ROMInfo{Offset: 0x00032CA8, Count: 815}},
+	"BRBE": {"Mega Man Battle Network 5: Team ProtoMan (US)", ROMInfo{Offset: 0x00032750, Count: 664}},
+	"BRKE": {"Mega Man Battle Network 5: Team Colonel (US)", ROMInfo{Offset: 0x00032754, Count: 664}},
+	"BRBJ": {"Rockman EXE 5: Team of Blues (JP)", ROMInfo{Offset: 0x000326e8, Count: 664}},
+	"BRKJ": {"Rockman EXE 5: Team of Colonel (JP)", ROMInfo{Offset: 0x000326ec, Count: 664}},
+	"BR4J": {"Rockman EXE 4.5: Real Operation (JP)", ROMInfo{Offset: 0x0002b39c, Count: 568}},
+	"B4BE": {"Mega Man Battle Network 4: Blue Moon (US)", ROMInfo{Offset: 0x00027968, Count: 616}},
+	"B4WE": {"Mega Man Battle Network 4: Red Sun (US)", ROMInfo{Offset: 0x00027964, Count: 616}},
+	"B4BJ": {"Rockman EXE 4: Tournament Blue Moon (JP)", ROMInfo{Offset: 0x00027880, Count: 616}},
+	"B4WJ": {"Rockman EXE 4: Tournament Red Sun (JP)", ROMInfo{Offset: 0x0002787c, Count: 616}},
+	"A6BE": {"Mega Man Battle Network 3: Blue (US)", ROMInfo{Offset: 0x000247a0, Count: 821}},
+	"A3XE": {"Mega Man Battle Network 3: White (US)", ROMInfo{Offset: 0x00024788, Count: 821}},
+	"A6BJ": {"Rockman EXE 3 Black (JP)", ROMInfo{Offset: 0x000248f8, Count: 565}},
+	"A3XJ": {"Rockman EXE 3 (JP)", ROMInfo{Offset: 0x000248e0, Count: 564}},
+	"AE2E": {"Mega Man Battle Network 2 (US)", ROMInfo{Offset: 0x0001e9fc, Count: 501}},
+	"AE2J": {"Rockman EXE 2 (JP)", ROMInfo{Offset: 0x0001e888, Count: 501}},
+	"AREE": {"Mega Man Battle Network (US)", ROMInfo{Offset: 0x00012690, Count: 344}},
+	"AREP": {"Mega Man Battle Network (EU)", ROMInfo{Offset: 0x0001269c, Count: 344}},
+	"AREJ": {"Rockman EXE: Battle Network (JP)", ROMInfo{Offset: 0x00012614, Count: 344}},
 }
 
 func FindROMInfo(romID string) *ROMInfo {
-	switch romID {
-	case "BR6E", "BR6P", "BR5E", "BR5P":
-		return &ROMInfo{0x00031CEC, 815}
-	case "BR6J", "BR5J":
-		return &ROMInfo{0x00032CA8, 815}
-	case "BRBE":
-		return &ROMInfo{0x00032750, 664}
-	case "BRKE":
-		return &ROMInfo{0x00032754, 664}
-	case "BRBJ":
-		return &ROMInfo{0x000326e8, 664}
-	case "BRKJ":
-		return &ROMInfo{0x000326ec, 664}
-	case "BR4J":
-		return &ROMInfo{0x0002b39c, 568}
-	case "B4BE":
-		return &ROMInfo{0x00027968, 616}
-	case "B4WE":
-		return &ROMInfo{0x00027964, 616}
-	case "B4BJ":
-		return &ROMInfo{0x00027880, 616}
-	case "B4WJ":
-		return &ROMInfo{0x0002787c, 616}
-	case "A6BE":
-		return &ROMInfo{0x000247a0, 821}
-	case "A3XE":
-		return &ROMInfo{0x00024788, 821}
-	case "A6BJ":
-		return &ROMInfo{0x000248f8, 565}
-	case "A3XJ":
-		return &ROMInfo{0x000248e0, 564}
-	case "AE2E":
-		return &ROMInfo{0x0001e9fc, 501}
-	case "AE2J":
-		return &ROMInfo{0x0001e888, 501}
-	case "AREE":
-		return &ROMInfo{0x00012690, 344}
-	case "AREP":
-		return &ROMInfo{0x0001269c, 344}
-	case "AREJ":
-		return &ROMInfo{0x00012614, 344}
-	}
-	return nil
+	gi, ok := KnownGames[romID]
+	if !ok {
+		return nil
+	}
+	return &gi.ROMInfo
 }
 
 type Flip uint8
@@ -75,6 +154,7 @@ const (
 	FlipBoth      = FlipH | FlipV
 )
 
+// OAMEntry is a single object in a frame. Flip is the upper nibble of the entry's size byte as stored. Only its FlipH and FlipV bits are drawn; what the low two bits mean isn't known, so they're kept as read rather than dropped. Nothing has checked whether any sprite uses them to pick affine parameters, and MakeImage draws every object unrotated and unscaled.
 type OAMEntry struct {
 	TileIndex     int
 	X             int
@@ -153,6 +233,9 @@ func ReadOAMEntry(r io.Reader) (*OAMEntry, error) {
 	case (3 << 3) | 2:
 		ent.WTiles = 4
 		ent.HTiles = 8
+
+	default:
+		return nil, fmt.Errorf("%w: oam entry size %d with size modifier %d", ErrUnsupportedFormat, size, sizeModifier)
 	}
 	return &ent, nil
 }
@@ -165,12 +248,53 @@ const (
 	FrameActionStop FrameAction = 0x80
 )
 
+// Event is whatever a frame's action carries besides the next/loop/stop bits, such as a sound or effect trigger. No such values have been seen: every action found so far is exactly one of the FrameAction constants, so Event is always EventNone for those. Anything else is passed through as is rather than being dropped.
+type Event uint16
+
+const EventNone Event = 0
+
 type Frame struct {
-	Palette    color.Palette
-	Delay      uint16
-	Action     FrameAction
+	Palette color.Palette
+	// Delay is how many refreshes the frame shows for. It's a full 16-bit field in the frame record, read as is.
+	Delay  uint16
+	Action FrameAction
+
+	// Tiles holds the frame's own tiles. Every frame has its own tile pointer, which is how the games stream new tiles into VRAM each frame, so tiles are never shared with or carried over from other frames and OAMEntries index into this frame's tiles only.
 	Tiles      []*image.Paletted
 	OAMEntries []OAMEntry
+
+	compressed bool
+
+	origin    image.Point
+	hasOrigin bool
+
+	// maxDim is the MaxFrameDim the frame was decoded with, or zero for the default.
+	maxDim int
+}
+
+func (f *Frame) maxFrameDim() int {
+	return DecodeOptions{MaxFrameDim: f.maxDim}.maxFrameDim()
+}
+
+// Origin returns the point the frame is anchored on, in the same coordinates as its OAM entries' X and Y, and whether the frame data gave one. BN frames carry no origin field: the 20-byte record ReadFrame reads is the tile, palette, unidentified third and OAM pointers followed by the delay and action, and the OAM entries' X and Y are signed offsets from the point the sprite is drawn at. So decoded frames fall back to the zero point, the center of MakeImage's canvas. Frames from other decoders can carry one with SetOrigin.
+func (f *Frame) Origin() (image.Point, bool) {
+	return f.origin, f.hasOrigin
+}
+
+// SetOrigin gives the frame an explicit origin, for decoders of formats whose frames record one.
+func (f *Frame) SetOrigin(p image.Point) {
+	f.origin = p
+	f.hasOrigin = true
+}
+
+// CanvasOrigin returns where the frame's origin lands on the canvas MakeImage draws on.
+func (f *Frame) CanvasOrigin() image.Point {
+	return image.Point{canvasSize / 2, canvasSize / 2}.Add(f.origin)
+}
+
+// Compressed reports whether the frame's tiles were stored LZ77 compressed on their own, rather than as plain tile data. Tiles inside a compressed sprite aren't compressed again, so this is false for them.
+func (f *Frame) Compressed() bool {
+	return f.compressed
 }
 
 func ReadTile(r io.Reader, bounds image.Rectangle) (*image.Paletted, error) {
@@ -188,6 +312,51 @@ func ReadTile(r io.Reader, bounds image.Rectangle) (*image.Paletted, error) {
 	return pimg, nil
 }
 
+// DecodeTiles splits GBA tile data into 8x8 tiles, at either 4 or 8 bits per pixel. It fails with ErrTruncated if data isn't a whole number of tiles.
+func DecodeTiles(data []byte, bpp int) ([]*image.Paletted, error) {
+	if bpp != 4 && bpp != 8 {
+		return nil, fmt.Errorf("%w: %d bits per pixel", ErrUnsupportedFormat, bpp)
+	}
+
+	tileSize := 8 * 8 * bpp / 8
+	if len(data)%tileSize != 0 {
+		return nil, fmt.Errorf("%w: %d bytes of tile data isn't a whole number of %d byte tiles", ErrTruncated, len(data), tileSize)
+	}
+
+	tiles := make([]*image.Paletted, len(data)/tileSize)
+	for i := range tiles {
+		raw := data[i*tileSize : (i+1)*tileSize]
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		if bpp == 8 {
+			copy(tile.Pix, raw)
+		} else {
+			for j, p := range raw {
+				tile.Pix[j*2] = p & 0xF
+				tile.Pix[j*2+1] = p >> 4
+			}
+		}
+		tiles[i] = tile
+	}
+
+	return tiles, nil
+}
+
+// DecodeTile4bpp decodes one 8x8 4bpp tile from the first 32 bytes of data, flipped as a tilemap entry would flip it. data must be at least 32 bytes long.
+func DecodeTile4bpp(data []byte, palette color.Palette, flipH bool, flipV bool) *image.Paletted {
+	tiles, _ := DecodeTiles(data[:8*8/2], 4)
+	tile := tiles[0]
+	tile.Palette = palette
+
+	if flipH {
+		paletted.FlipHorizontal(tile)
+	}
+	if flipV {
+		paletted.FlipVertical(tile)
+	}
+
+	return tile
+}
+
 func ReadPalette(r io.Reader) (color.Palette, error) {
 	var palette color.Palette
 
@@ -197,7 +366,7 @@ func ReadPalette(r io.Reader) (color.Palette, error) {
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			return nil, err
+			return nil, &ParseError{int64(len(palette) * 2), checkTruncated(err)}
 		}
 
 		palette = append(palette, bgr555.ToRGBA(c))
@@ -205,8 +374,103 @@ func ReadPalette(r io.Reader) (color.Palette, error) {
 	return palette, nil
 }
 
+// ColorAdjust adjusts colors decoded from BGR555, to match how a particular screen or emulator shows them. Each channel c, from 0 to 1, becomes Brightness * c^(1/Gamma), so gammas over 1 lighten midtones. Zero for either means 1, so the zero value leaves colors as decoded.
+type ColorAdjust struct {
+	Brightness float64
+	Gamma      float64
+}
+
+// IsIdentity reports whether a leaves every color as it is.
+func (a ColorAdjust) IsIdentity() bool {
+	return (a.Brightness == 0 || a.Brightness == 1) && (a.Gamma == 0 || a.Gamma == 1)
+}
+
+// Color applies a to c. Adjusted colors are color.RGBA, like the ones decoded from BGR555, so code that expects decoded palettes to hold color.RGBA keeps working.
+func (a ColorAdjust) Color(c color.Color) color.Color {
+	if a.IsIdentity() {
+		return c
+	}
+
+	brightness, gamma := a.Brightness, a.Gamma
+	if brightness == 0 {
+		brightness = 1
+	}
+	if gamma == 0 {
+		gamma = 1
+	}
+
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	adjust := func(v uint8) uint8 {
+		f := brightness * math.Pow(float64(v)/255, 1/gamma)
+		return uint8(math.Round(math.Max(0, math.Min(1, f)) * 255))
+	}
+	return color.RGBAModel.Convert(color.NRGBA{adjust(nc.R), adjust(nc.G), adjust(nc.B), nc.A})
+}
+
+// Palette returns a copy of p with every entry adjusted, or p itself if a leaves colors as they are.
+func (a ColorAdjust) Palette(p color.Palette) color.Palette {
+	if a.IsIdentity() {
+		return p
+	}
+
+	adjusted := make(color.Palette, len(p))
+	for i, c := range p {
+		adjusted[i] = a.Color(c)
+	}
+	return adjusted
+}
+
+// DecodePalette decodes n BGR555 entries from raw, as the hardware stores them. ColorAdjust can adjust them afterwards.
+func DecodePalette(raw []byte, n int) (color.Palette, error) {
+	if len(raw) < n*2 {
+		return nil, &ParseError{int64(len(raw)), fmt.Errorf("%w: need %d bytes for %d palette entries", ErrTruncated, n*2, n)}
+	}
+
+	palette := make(color.Palette, n)
+	for i := 0; i < n; i++ {
+		palette[i] = bgr555.ToRGBA(binary.LittleEndian.Uint16(raw[i*2:]))
+	}
+	return palette, nil
+}
+
+// DecodeOptions are the checks and limits sprites are decoded with. The zero value uses the defaults.
+type DecodeOptions struct {
+	// Strict makes ReadFrame fail on sizes that don't add up, such as tile data that isn't a whole number of tiles, rather than reading what it can. These don't stop a sprite from decoding, but usually mean the data is misaligned.
+	Strict bool
+
+	// MaxAnimationFrames is the most frames an animation may have. Animations have no frame count, only an action on the last frame, so a misaligned frame list would otherwise be read until the data runs out. Zero means DefaultMaxAnimationFrames.
+	MaxAnimationFrames int
+
+	// MaxFrameDim is the largest width or height a frame may have. Frames past it fail with ErrOutOfRange instead of being allocated, which usually means the sprite table offset is wrong. Zero means DefaultMaxFrameDim.
+	MaxFrameDim int
+}
+
+const (
+	DefaultMaxAnimationFrames = 256
+	DefaultMaxFrameDim        = 512
+)
+
+func (o DecodeOptions) maxAnimationFrames() int {
+	if o.MaxAnimationFrames == 0 {
+		return DefaultMaxAnimationFrames
+	}
+	return o.MaxAnimationFrames
+}
+
+func (o DecodeOptions) maxFrameDim() int {
+	if o.MaxFrameDim == 0 {
+		return DefaultMaxFrameDim
+	}
+	return o.MaxFrameDim
+}
+
 func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
-	var fr Frame
+	return DecodeOptions{}.ReadFrame(r, offset)
+}
+
+// ReadFrame is like the package-level ReadFrame, but checks the frame against o.
+func (o DecodeOptions) ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
+	fr := Frame{maxDim: o.maxFrameDim()}
 
 	var rawFr struct {
 		TilesPtr  uint32
@@ -234,7 +498,7 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 
 	// Decode tiles.
 	if _, err := r.Seek(offset+4+int64(rawFr.TilesPtr), os.SEEK_SET); err != nil {
-		return fr, fmt.Errorf("%w while seeking to tiles at tile pointer 0x%08x", err, rawFr.TilesPtr)
+		return fr, fmt.Errorf("%w while seeking to tiles at tile pointer 0x%08x", withKind(ErrBadPointer, err), rawFr.TilesPtr)
 	}
 
 	var tilesByteSize uint32
@@ -242,20 +506,54 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 		return fr, fmt.Errorf("%w reading tiles at tile pointer 0x%08x", err, rawFr.TilesPtr)
 	}
 
+	// Tile data is normally a byte size followed by the tiles, but it can also be LZ77 compressed, starting with the 0x10 compression header byte and the decompressed size. A byte size of whole tiles is a multiple of 32, so its low byte is never 0x10.
+	if tilesByteSize&0xff == 0x10 && (tilesByteSize>>8)%(8*8/2) == 0 {
+		fr.compressed = true
+		tilesByteSize >>= 8
+	}
+
+	if o.Strict && tilesByteSize%(8*8/2) != 0 {
+		return fr, fmt.Errorf("%w: tile data at tile pointer 0x%08x is %d bytes, not a whole number of tiles", ErrUnsupportedFormat, rawFr.TilesPtr, tilesByteSize)
+	}
+
 	numTiles := tilesByteSize / (8 * 8 / 2)
+	if maxTiles := (fr.maxDim / 8) * (fr.maxDim / 8); int64(numTiles) > int64(maxTiles) {
+		return fr, fmt.Errorf("%w: %d tiles at tile pointer 0x%08x won't fit in a %dx%d frame", ErrOutOfRange, numTiles, rawFr.TilesPtr, fr.maxDim, fr.maxDim)
+	}
+
+	var rawTiles []byte
+	if fr.compressed {
+		if _, err := r.Seek(-4, os.SEEK_CUR); err != nil {
+			return fr, err
+		}
 
-	fr.Tiles = make([]*image.Paletted, numTiles)
-	for i := 0; i < int(numTiles); i++ {
-		var err error
-		fr.Tiles[i], err = ReadTile(r, image.Rect(0, 0, 8, 8))
+		rawTiles, err = lz77.Decompress(r)
 		if err != nil {
-			return fr, fmt.Errorf("%w while reading tile %d at pointer 0x%08x", err, i, rawFr.TilesPtr)
+			if errors.Is(err, lz77.ErrInvalid) {
+				err = withKind(ErrUnsupportedFormat, err)
+			}
+			return fr, fmt.Errorf("%w while decompressing tiles at pointer 0x%08x", checkTruncated(err), rawFr.TilesPtr)
+		}
+	} else {
+		rawTiles = make([]byte, numTiles*8*8/2)
+		if _, err := io.ReadFull(r, rawTiles); err != nil {
+			return fr, fmt.Errorf("%w while reading tiles at pointer 0x%08x", err, rawFr.TilesPtr)
 		}
 	}
 
+	if !o.Strict {
+		// Compressed tile data can decompress to a partial tile at the end, which nothing can draw.
+		rawTiles = rawTiles[:len(rawTiles)/(8*8/2)*(8*8/2)]
+	}
+
+	fr.Tiles, err = DecodeTiles(rawTiles, 4)
+	if err != nil {
+		return fr, fmt.Errorf("%w while decoding tiles at pointer 0x%08x", err, rawFr.TilesPtr)
+	}
+
 	// Decode palette.
 	if _, err := r.Seek(offset+4+int64(rawFr.PalPtr), os.SEEK_SET); err != nil {
-		return fr, fmt.Errorf("%w while seeking to palette at palette pointer 0x%08x", err, rawFr.PalPtr)
+		return fr, fmt.Errorf("%w while seeking to palette at palette pointer 0x%08x", withKind(ErrBadPointer, err), rawFr.PalPtr)
 	}
 
 	var paletteByteSize uint32
@@ -278,7 +576,7 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 			break
 		}
 
-		palette, err := ReadPalette(bytes.NewBuffer(raw[:]))
+		palette, err := DecodePalette(raw[:], 16)
 		if err != nil {
 			return fr, fmt.Errorf("%w while reading palbank %d at palette pointer 0x%08x", err, i, rawFr.PalPtr)
 		}
@@ -290,7 +588,7 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 
 	// Decode OAM entries.
 	if _, err := r.Seek(offset+4+int64(rawFr.OAMPtrPtr), os.SEEK_SET); err != nil {
-		return fr, fmt.Errorf("%w while seeking to OAM pointer at OAM pointer pointer 0x%08x", err, rawFr.OAMPtrPtr)
+		return fr, fmt.Errorf("%w while seeking to OAM pointer at OAM pointer pointer 0x%08x", withKind(ErrBadPointer, err), rawFr.OAMPtrPtr)
 	}
 
 	var oamPtr uint32
@@ -299,7 +597,7 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 	}
 
 	if _, err := r.Seek(offset+4+int64(rawFr.OAMPtrPtr+oamPtr), os.SEEK_SET); err != nil {
-		return fr, fmt.Errorf("%w while reading OAM at OAM pointer 0x%08x", err, oamPtr)
+		return fr, fmt.Errorf("%w while seeking to OAM at OAM pointer 0x%08x", withKind(ErrBadPointer, err), oamPtr)
 	}
 
 	for i := 0; ; i++ {
@@ -318,15 +616,59 @@ func ReadFrame(r io.ReadSeeker, offset int64) (Frame, error) {
 	return fr, nil
 }
 
-func (f *Frame) MakeImage() *image.Paletted {
+// canvasSize is the width and height of the canvas MakeImage draws frames on, with OAM position 0, 0 at its center.
+const canvasSize = 512
+
+func (f *Frame) MakeImage() (*image.Paletted, error) {
+	return f.MakeImageLayer(nil)
+}
+
+// ObjectMask picks OAM objects by their position in Frame.OAMEntries. The packed entries in sprite data have no priority bits, so object order is the only way to tell layers apart.
+type ObjectMask func(i int, ent OAMEntry) bool
+
+// ObjectIndexes returns a mask selecting just the given objects.
+func ObjectIndexes(indexes ...int) ObjectMask {
+	set := map[int]bool{}
+	for _, i := range indexes {
+		set[i] = true
+	}
+	return func(i int, ent OAMEntry) bool {
+		return set[i]
+	}
+}
+
+// MakeImageLayer renders the frame like MakeImage, but only draws the objects mask selects. A nil mask draws every object. A frame without palette data gets a single transparent entry, and fails with ErrMissingColor if it draws anything else.
+func (f *Frame) MakeImageLayer(mask ObjectMask) (*image.Paletted, error) {
+	var extent image.Rectangle
+	for _, oamEntry := range f.OAMEntries {
+		extent = extent.Union(image.Rect(oamEntry.X, oamEntry.Y, oamEntry.X+oamEntry.WTiles*8, oamEntry.Y+oamEntry.HTiles*8))
+	}
+	if maxDim := f.maxFrameDim(); extent.Dx() > maxDim || extent.Dy() > maxDim {
+		return nil, fmt.Errorf("%w: frame is %dx%d, more than %d", ErrOutOfRange, extent.Dx(), extent.Dy(), maxDim)
+	}
+
 	palSize := 256
 	if len(f.Palette) < palSize {
 		palSize = len(f.Palette)
 	}
 
-	img := image.NewPaletted(image.Rect(0, 0, 512, 512), f.Palette[:palSize])
+	palette := f.Palette[:palSize]
+	if len(palette) == 0 {
+		// Frames without palette data still get a transparent entry so the image can be encoded.
+		palette = color.Palette{color.RGBA{}}
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, canvasSize, canvasSize), palette)
+
+	for i, oamEntry := range f.OAMEntries {
+		if mask != nil && !mask(i, oamEntry) {
+			continue
+		}
+
+		if n := oamEntry.TileIndex + oamEntry.WTiles*oamEntry.HTiles; n > len(f.Tiles) {
+			return nil, fmt.Errorf("%w: oam entry %d needs %d tiles but frame only has %d", ErrOutOfRange, i, n, len(f.Tiles))
+		}
 
-	for _, oamEntry := range f.OAMEntries {
 		oamImg := image.NewPaletted(image.Rect(0, 0, oamEntry.WTiles*8, oamEntry.HTiles*8), img.Palette)
 
 		for j := 0; j < oamEntry.HTiles; j++ {
@@ -360,15 +702,458 @@ func (f *Frame) MakeImage() *image.Paletted {
 		), oamImg, image.Point{})
 	}
 
-	return img
+	if len(f.Palette) == 0 {
+		for _, p := range img.Pix {
+			if p != 0 {
+				return nil, fmt.Errorf("%w: frame has no palette data but draws index %d", ErrMissingColor, p)
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// MakeNRGBA renders the frame like MakeImage, on the same canvas, but straight into an NRGBA image instead of converting a paletted one, for callers that want RGBA output anyway. Pixels whose index is past the end of the palette are left transparent.
+func (f *Frame) MakeNRGBA() (*image.NRGBA, error) {
+	var extent image.Rectangle
+	for _, oamEntry := range f.OAMEntries {
+		extent = extent.Union(image.Rect(oamEntry.X, oamEntry.Y, oamEntry.X+oamEntry.WTiles*8, oamEntry.Y+oamEntry.HTiles*8))
+	}
+	if maxDim := f.maxFrameDim(); extent.Dx() > maxDim || extent.Dy() > maxDim {
+		return nil, fmt.Errorf("%w: frame is %dx%d, more than %d", ErrOutOfRange, extent.Dx(), extent.Dy(), maxDim)
+	}
+
+	var colors [256]color.NRGBA
+	for i, c := range f.Palette {
+		if i >= len(colors) {
+			break
+		}
+		colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, canvasSize, canvasSize))
+
+	for i, oamEntry := range f.OAMEntries {
+		if n := oamEntry.TileIndex + oamEntry.WTiles*oamEntry.HTiles; n > len(f.Tiles) {
+			return nil, fmt.Errorf("%w: oam entry %d needs %d tiles but frame only has %d", ErrOutOfRange, i, n, len(f.Tiles))
+		}
+
+		w, h := oamEntry.WTiles*8, oamEntry.HTiles*8
+		for ly := 0; ly < h; ly++ {
+			y := oamEntry.Y + canvasSize/2 + ly
+			if y < 0 || y >= canvasSize {
+				continue
+			}
+
+			// Flipping mirrors the whole object, not each tile, so flip the coordinates into the unflipped object.
+			sy := ly
+			if oamEntry.Flip&FlipV != 0 {
+				sy = h - 1 - ly
+			}
+
+			for lx := 0; lx < w; lx++ {
+				x := oamEntry.X + canvasSize/2 + lx
+				if x < 0 || x >= canvasSize {
+					continue
+				}
+
+				sx := lx
+				if oamEntry.Flip&FlipH != 0 {
+					sx = w - 1 - lx
+				}
+
+				p := f.Tiles[oamEntry.TileIndex+(sy/8)*oamEntry.WTiles+sx/8].Pix[(sy%8)*8+sx%8]
+				if p == 0 {
+					continue
+				}
+
+				idx := int(p) + 16*oamEntry.PaletteOffset
+				if idx >= len(colors) || idx >= len(f.Palette) {
+					continue
+				}
+
+				// Indexed drawing lets later objects cover earlier ones with any drawn index, transparent or not.
+				c := colors[idx]
+				o := img.PixOffset(x, y)
+				img.Pix[o+0] = c.R
+				img.Pix[o+1] = c.G
+				img.Pix[o+2] = c.B
+				img.Pix[o+3] = c.A
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func (f *Frame) Event() Event {
+	return Event(f.Action &^ FrameActionLoop)
+}
+
+// IndexAt returns the palette index MakeImage would draw at (x, y), relative to the frame's origin, without rendering the frame. The bool reports whether any OAM object covers the point; a covered point can still be transparent.
+func (f *Frame) IndexAt(x int, y int) (uint8, bool) {
+	covered := false
+
+	// Later objects draw over earlier ones, so look from the top down.
+	for i := len(f.OAMEntries) - 1; i >= 0; i-- {
+		oamEntry := f.OAMEntries[i]
+
+		lx := x - oamEntry.X
+		ly := y - oamEntry.Y
+		if lx < 0 || ly < 0 || lx >= oamEntry.WTiles*8 || ly >= oamEntry.HTiles*8 {
+			continue
+		}
+		covered = true
+
+		if oamEntry.Flip&FlipH != 0 {
+			lx = oamEntry.WTiles*8 - 1 - lx
+		}
+		if oamEntry.Flip&FlipV != 0 {
+			ly = oamEntry.HTiles*8 - 1 - ly
+		}
+
+		tileIdx := oamEntry.TileIndex + (ly/8)*oamEntry.WTiles + lx/8
+		if tileIdx >= len(f.Tiles) {
+			continue
+		}
+
+		if p := f.Tiles[tileIdx].Pix[(ly%8)*8+lx%8]; p != 0 {
+			return p + uint8(16*oamEntry.PaletteOffset), true
+		}
+	}
+
+	return 0, covered
+}
+
+// IndexHistogram counts the pixels the frame draws with each palette index. Only pixels some OAM object covers are counted, so index 0 counts the transparent pixels inside objects rather than the whole canvas.
+func (f *Frame) IndexHistogram() map[uint8]int {
+	var extent image.Rectangle
+	for _, oamEntry := range f.OAMEntries {
+		extent = extent.Union(image.Rect(oamEntry.X, oamEntry.Y, oamEntry.X+oamEntry.WTiles*8, oamEntry.Y+oamEntry.HTiles*8))
+	}
+
+	hist := map[uint8]int{}
+	for y := extent.Min.Y; y < extent.Max.Y; y++ {
+		for x := extent.Min.X; x < extent.Max.X; x++ {
+			if idx, ok := f.IndexAt(x, y); ok {
+				hist[idx]++
+			}
+		}
+	}
+	return hist
+}
+
+// paletteKey converts c for matching palette entries, folding every fully transparent color into color.RGBA{}, so that transparency maps to one entry wherever each palette keeps it.
+func paletteKey(c color.Color) color.RGBA {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	if rgba.A == 0 {
+		return color.RGBA{}
+	}
+	return rgba
+}
+
+func (f *Frame) makeImageInPalette(p color.Palette, nearest bool) (*image.Paletted, error) {
+	img, err := f.MakeImage()
+	if err != nil {
+		return nil, err
+	}
+
+	exact := make(map[color.RGBA]uint8, len(p))
+	for i := len(p) - 1; i >= 0; i-- {
+		exact[paletteKey(p[i])] = uint8(i)
+	}
+
+	var used [256]bool
+	for _, v := range img.Pix {
+		used[v] = true
+	}
+
+	// Index 0 is never drawn, so it's transparent whatever color the frame's palette has there.
+	var remap [256]uint8
+	if t, ok := exact[color.RGBA{}]; ok {
+		remap[0] = t
+	} else if nearest {
+		remap[0] = uint8(p.Index(color.RGBA{}))
+	} else if used[0] {
+		return nil, fmt.Errorf("%w: no transparent entry", ErrMissingColor)
+	}
+
+	for i, c := range img.Palette {
+		if !used[i] || i == 0 {
+			continue
+		}
+		if j, ok := exact[paletteKey(c)]; ok {
+			remap[i] = j
+		} else if nearest {
+			remap[i] = uint8(p.Index(c))
+		} else {
+			return nil, fmt.Errorf("%w: palette entry %d (%v)", ErrMissingColor, i, c)
+		}
+	}
+
+	for i, v := range img.Pix {
+		img.Pix[i] = remap[v]
+	}
+	img.Palette = p
+
+	return img, nil
+}
+
+// MakeImageWithPalette renders the frame like MakeImage, but with pixels remapped into p, e.g. from BuildGlobalPalette. Colors missing from p are mapped to their nearest match.
+func (f *Frame) MakeImageWithPalette(p color.Palette) (*image.Paletted, error) {
+	return f.makeImageInPalette(p, true)
+}
+
+// MakeImageInto is like MakeImageWithPalette, but fails with ErrMissingColor if the frame uses a color that isn't in p.
+func (f *Frame) MakeImageInto(p color.Palette) (*image.Paletted, error) {
+	return f.makeImageInPalette(p, false)
+}
+
+// BuildGlobalPalette returns a palette holding every distinct color used by the given sprites, with the transparent color at index 0. Transparent entries are merged into index 0 whatever index they had in each frame's palette, as is the first entry of every palbank, which is never drawn, and MakeImageInto maps them there too. It fails if there are more than 256 colors, in which case the sprites need to keep their own palettes instead.
+func BuildGlobalPalette(anims [][]Animation) (color.Palette, error) {
+	palette := color.Palette{color.RGBA{}}
+	seen := map[color.RGBA]bool{{}: true}
+
+	for _, spriteAnims := range anims {
+		for _, anim := range spriteAnims {
+			for _, frame := range anim.Frames {
+				for i, c := range frame.Palette {
+					if i%16 == 0 {
+						// The first entry of every palbank is transparent when drawn.
+						continue
+					}
+					rgba := paletteKey(c)
+					if seen[rgba] {
+						continue
+					}
+					seen[rgba] = true
+					palette = append(palette, rgba)
+				}
+			}
+		}
+	}
+
+	if len(palette) > 256 {
+		return nil, fmt.Errorf("%w: sprites use %d distinct colors, but a global palette can only hold 256", ErrOutOfRange, len(palette))
+	}
+
+	return palette, nil
 }
 
 type Animation struct {
 	Frames []Frame
+
+	// Speed scales how fast the animation plays. BN's animation data has no such field: an animation pointer leads straight to its frame list, and the 3-byte header before the animation count is per sprite, not per animation. Speed is always 1 for decoded animations.
+	Speed float64
+
+	// Name is empty for decoded animations, since BN's animation data doesn't name them.
+	Name string
+
+	kind Kind
+}
+
+// FrameRate is the GBA's refresh rate in Hz. Frame delays are counted in refreshes.
+const FrameRate = 59.7275
+
+// TicksToDuration converts a frame delay to wall-clock time.
+func TicksToDuration(ticks int) time.Duration {
+	return time.Duration(float64(ticks) * float64(time.Second) / FrameRate)
+}
+
+// Duration returns how long one pass through the animation takes.
+func (a Animation) Duration() time.Duration {
+	ticks := 0
+	for _, frame := range a.Frames {
+		ticks += int(frame.Delay)
+	}
+
+	speed := a.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	return time.Duration(float64(TicksToDuration(ticks)) / speed)
+}
+
+// FrameAt returns the frame showing ms milliseconds into playback, and its index. Times past the end wrap around if the last frame loops, and otherwise stay on the last frame, as the games do after a stop. Speed is taken into account. It returns -1 for an animation with no frames.
+func (a Animation) FrameAt(ms float64) (int, Frame) {
+	if len(a.Frames) == 0 {
+		return -1, Frame{}
+	}
+	last := len(a.Frames) - 1
+
+	total := 0
+	for _, frame := range a.Frames {
+		total += int(frame.Delay)
+	}
+	if total == 0 {
+		return last, a.Frames[last]
+	}
+
+	speed := a.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	// Nudge by a tiny fraction of a tick, so that a time computed from a frame boundary, like TicksToDuration's, doesn't round to just before it.
+	ticks := ms*speed*FrameRate/1000 + 1e-6
+	if ticks < 0 {
+		ticks = 0
+	}
+
+	if ticks >= float64(total) {
+		if a.Frames[last].Action&FrameActionLoop != FrameActionLoop {
+			return last, a.Frames[last]
+		}
+		ticks = math.Mod(ticks, float64(total))
+	}
+
+	acc := 0
+	for i, frame := range a.Frames {
+		acc += int(frame.Delay)
+		if ticks < float64(acc) {
+			return i, frame
+		}
+	}
+	return last, a.Frames[last]
+}
+
+// IsEmpty reports whether no frame of any animation has an object to draw, as with the placeholder sprites some tables fill unused slots with.
+func IsEmpty(anims []Animation) bool {
+	for _, anim := range anims {
+		for _, frame := range anim.Frames {
+			if len(frame.OAMEntries) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// UnionBounds returns the smallest rectangle containing the opaque pixels of every frame, relative to the origin that frames are drawn around. Frames that fail to render are left out.
+func (a Animation) UnionBounds() image.Rectangle {
+	var bounds image.Rectangle
+	for _, frame := range a.Frames {
+		img, err := frame.MakeImage()
+		if err != nil {
+			continue
+		}
+
+		if trim := paletted.FindTrim(img); !trim.Empty() {
+			bounds = bounds.Union(trim.Sub(frame.CanvasOrigin()))
+		}
+	}
+	return bounds
+}
+
+// FrameEvent is a frame whose action does more than advance to the next frame, as found by Animation.Events.
+type FrameEvent struct {
+	FrameIndex int
+	// Action is the frame's whole action, event bits included. Frame.Event splits those out.
+	Action FrameAction
+}
+
+// Events returns the animation's actions as a track of events, one for every frame whose action isn't FrameActionNext, in frame order. Frames only loop or stop so far, but engines can map these onto their own animation events either way.
+func (a Animation) Events() []FrameEvent {
+	var events []FrameEvent
+	for i, frame := range a.Frames {
+		if frame.Action != FrameActionNext {
+			events = append(events, FrameEvent{i, frame.Action})
+		}
+	}
+	return events
+}
+
+// FrameSizes returns the distinct sizes of the animation's frames once trimmed to their opaque pixels, in the order they first appear. Frames that fail to render or have nothing to draw are left out, as with UnionBounds.
+func (a Animation) FrameSizes() []image.Point {
+	var sizes []image.Point
+	seen := map[image.Point]bool{}
+	for _, frame := range a.Frames {
+		img, err := frame.MakeImage()
+		if err != nil {
+			continue
+		}
+
+		trim := paletted.FindTrim(img)
+		if trim.Empty() || seen[trim.Size()] {
+			continue
+		}
+		seen[trim.Size()] = true
+		sizes = append(sizes, trim.Size())
+	}
+	return sizes
+}
+
+// RenderWalk renders every frame of the animation onto a canvas of its own, all of them the same size, so that the character travels by how much each frame's origin moved since the previous one. Frames are placed relative to their origin, and the origin itself is moved by the negated change in origin from the previous frame, accumulated from the first frame on. The canvas is just big enough for every frame at its place. bndumper's -mode aligned is different: it pins every frame's origin to the same pixel, so only the content moves.
+func RenderWalk(anim Animation) ([]image.Image, error) {
+	imgs := make([]*image.Paletted, len(anim.Frames))
+	trims := make([]image.Rectangle, len(anim.Frames))
+	// places[i] is where frame i's trimmed pixels go, relative to the first frame's origin.
+	places := make([]image.Rectangle, len(anim.Frames))
+
+	var offset image.Point
+	var bounds image.Rectangle
+	for i := range anim.Frames {
+		frame := &anim.Frames[i]
+		img, err := frame.MakeImage()
+		if err != nil {
+			return nil, fmt.Errorf("%w while rendering frame %d", err, i)
+		}
+
+		if i > 0 {
+			prev, _ := anim.Frames[i-1].Origin()
+			cur, _ := frame.Origin()
+			offset = offset.Sub(cur.Sub(prev))
+		}
+
+		trim := paletted.FindTrim(img)
+		imgs[i], trims[i] = img, trim
+		places[i] = trim.Sub(frame.CanvasOrigin()).Add(offset)
+		if !trim.Empty() {
+			bounds = bounds.Union(places[i])
+		}
+	}
+
+	out := make([]image.Image, len(imgs))
+	for i, img := range imgs {
+		dst := image.NewPaletted(image.Rectangle{Max: bounds.Size()}, img.Palette)
+		paletted.DrawOver(dst, places[i].Sub(bounds.Min), img, trims[i].Min)
+		out[i] = dst
+	}
+
+	return out, nil
+}
+
+// OnionSkin draws the last n frames of the animation over each other, anchored on their origins, with older frames fading out: frame k of n, counting from the oldest, is drawn at k/n opacity, so the newest is fully opaque. n <= 0 means every frame. Frames that fail to render are left out, as with UnionBounds.
+func OnionSkin(anim Animation, n int) image.Image {
+	frames := anim.Frames
+	if n > 0 && n < len(frames) {
+		frames = frames[len(frames)-n:]
+	}
+
+	bounds := Animation{Frames: frames}.UnionBounds()
+	dst := image.NewNRGBA(image.Rectangle{Max: bounds.Size()})
+
+	for i, frame := range frames {
+		img, err := frame.MakeImage()
+		if err != nil {
+			continue
+		}
+
+		mask := image.NewUniform(color.Alpha{uint8(0xff * (i + 1) / len(frames))})
+		draw.DrawMask(dst, dst.Rect, img, bounds.Min.Add(frame.CanvasOrigin()), mask, image.Point{}, draw.Over)
+	}
+
+	return dst
 }
 
 func ReadAnimation(r io.ReadSeeker, offset int64) (Animation, error) {
-	var anim Animation
+	return DecodeOptions{}.ReadAnimation(r, offset)
+}
+
+// ReadAnimation is like the package-level ReadAnimation, but checks the animation and its frames against o.
+func (o DecodeOptions) ReadAnimation(r io.ReadSeeker, offset int64) (Animation, error) {
+	anim := Animation{Speed: 1}
 
 	var animPtr uint32
 	if err := binary.Read(r, binary.LittleEndian, &animPtr); err != nil {
@@ -384,11 +1169,15 @@ func ReadAnimation(r io.ReadSeeker, offset int64) (Animation, error) {
 	}()
 
 	if _, err := r.Seek(offset+4+int64(animPtr), os.SEEK_SET); err != nil {
-		return anim, fmt.Errorf("%w while seeking to animation pointer 0x%08x", err, animPtr)
+		return anim, fmt.Errorf("%w while seeking to animation pointer 0x%08x", withKind(ErrBadPointer, err), animPtr)
 	}
 
 	for i := 0; ; i++ {
-		frame, err := ReadFrame(r, offset)
+		if max := o.maxAnimationFrames(); i >= max {
+			return anim, fmt.Errorf("%w: animation at animation pointer 0x%08x has more than %d frames without a loop or stop", ErrOutOfRange, animPtr, max)
+		}
+
+		frame, err := o.ReadFrame(r, offset)
 		if err != nil {
 			return anim, fmt.Errorf("%w while reading frame %d at animation pointer 0x%08x", err, i, animPtr)
 		}
@@ -403,6 +1192,11 @@ func ReadAnimation(r io.ReadSeeker, offset int64) (Animation, error) {
 }
 
 func ReadAnimations(r io.ReadSeeker, offset int64) ([]Animation, error) {
+	return DecodeOptions{}.ReadAnimations(r, offset)
+}
+
+// ReadAnimations is like the package-level ReadAnimations, but checks every animation against o.
+func (o DecodeOptions) ReadAnimations(r io.ReadSeeker, offset int64) ([]Animation, error) {
 	if _, err := io.CopyN(io.Discard, r, 3); err != nil {
 		return nil, fmt.Errorf("%w while discarding header", err)
 	}
@@ -414,7 +1208,7 @@ func ReadAnimations(r io.ReadSeeker, offset int64) ([]Animation, error) {
 
 	anims := make([]Animation, n)
 	for i := 0; i < len(anims); i++ {
-		anim, err := ReadAnimation(r, offset)
+		anim, err := o.ReadAnimation(r, offset)
 		if err != nil {
 			return nil, fmt.Errorf("%w while reading animation %d", err, i)
 		}
@@ -424,10 +1218,75 @@ func ReadAnimations(r io.ReadSeeker, offset int64) ([]Animation, error) {
 	return anims, nil
 }
 
-func ReadNext(r io.ReadSeeker) ([]Animation, error) {
-	var animPtr uint32
-	if err := binary.Read(r, binary.LittleEndian, &animPtr); err != nil {
-		return nil, fmt.Errorf("%w while reading sprite pointer 0x%08x", err, animPtr)
+// openSprite follows a sprite pointer, decompressing the sprite if need be, and leaves the returned reader at the start of the sprite's animations. buf is the decompressed data, or nil if the sprite isn't compressed.
+func openSprite(r io.ReadSeeker, animPtr uint32) (animR io.ReadSeeker, realPtr int64, buf []byte, err error) {
+	animR = r
+
+	if animPtr&0x08000000 == 0 {
+		return nil, 0, nil, fmt.Errorf("%w: sprite pointer 0x%08x is not a ROM pointer", ErrBadPointer, animPtr)
+	}
+
+	isLZ77 := animPtr&0x80000000 == 0x80000000
+	realPtr = int64(animPtr & ^uint32(0x88000000))
+
+	if isLZ77 {
+		if _, err := r.Seek(realPtr, os.SEEK_SET); err != nil {
+			return nil, 0, nil, fmt.Errorf("%w while seeking to LZ77 sprite pointer 0x%08x", withKind(ErrBadPointer, err), animPtr)
+		}
+
+		buf, err = lz77.Decompress(r)
+		if err != nil {
+			if errors.Is(err, lz77.ErrInvalid) {
+				err = withKind(ErrUnsupportedFormat, err)
+			}
+			return nil, 0, nil, fmt.Errorf("%w while decompressing LZ77 sprite pointer 0x%08x", checkTruncated(err), animPtr)
+		}
+
+		animR = bytes.NewReader(buf)
+		realPtr = 4
+	}
+
+	if _, err := animR.Seek(realPtr, os.SEEK_SET); err != nil {
+		return nil, 0, nil, fmt.Errorf("%w while seeking sprite pointer 0x%08x", withKind(ErrBadPointer, err), animPtr)
+	}
+
+	return animR, realPtr, buf, nil
+}
+
+// extentReader records the end of the furthest read made through it.
+type extentReader struct {
+	io.ReadSeeker
+	pos int64
+	end int64
+}
+
+func (r *extentReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.pos += int64(n)
+	if r.pos > r.end {
+		r.end = r.pos
+	}
+	return n, err
+}
+
+func (r *extentReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.pos = pos
+	}
+	return pos, err
+}
+
+// ReadRaw reads the next sprite pointer like ReadNext, but returns the bytes the sprite is parsed from instead of decoding them. For a compressed sprite that's the whole LZ77 output. Otherwise, it's the ROM from the sprite pointer up to the furthest byte a decode reads, so the sprite must decode for ReadRaw to work.
+func ReadRaw(r io.ReadSeeker) ([]byte, error) {
+	return ROMInfo{}.ReadRaw(r)
+}
+
+// ReadRaw is like the package-level ReadRaw, but reads the sprite pointer the way ri's table stores it.
+func (ri ROMInfo) ReadRaw(r io.ReadSeeker) ([]byte, error) {
+	animPtr, err := ri.ReadPointer(r)
+	if err != nil {
+		return nil, err
 	}
 
 	retOffset, err := r.Seek(0, os.SEEK_CUR)
@@ -439,33 +1298,89 @@ func ReadNext(r io.ReadSeeker) ([]Animation, error) {
 		r.Seek(retOffset, os.SEEK_SET)
 	}()
 
-	animR := r
+	animR, realPtr, buf, err := openSprite(r, animPtr)
+	if err != nil {
+		return nil, err
+	}
 
-	isLZ77 := animPtr&0x80000000 == 0x80000000
-	realPtr := animPtr & ^uint32(0x88000000)
+	if buf != nil {
+		return buf, nil
+	}
 
-	if isLZ77 {
-		if _, err := r.Seek(int64(realPtr), os.SEEK_SET); err != nil {
-			return nil, fmt.Errorf("%w while seeking to LZ77 sprite pointer 0x%08x", err, animPtr)
-		}
+	er := &extentReader{animR, realPtr, realPtr}
+	if _, err := ri.Decode.ReadAnimations(er, realPtr); err != nil {
+		return nil, fmt.Errorf("%w while reading sprite at sprite pointer 0x%08x", checkTruncated(err), animPtr)
+	}
 
-		buf, err := lz77.Decompress(r)
-		if err != nil {
-			return nil, fmt.Errorf("%w while decompressing LZ77 sprite pointer 0x%08x", err, animPtr)
-		}
+	raw := make([]byte, er.end-realPtr)
+	if _, err := r.Seek(realPtr, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("%w while reading sprite at sprite pointer 0x%08x", err, animPtr)
+	}
 
-		animR = bytes.NewReader(buf)
-		realPtr = 4
+	return raw, nil
+}
+
+func ReadNext(r io.ReadSeeker) ([]Animation, error) {
+	return ROMInfo{}.ReadNext(r, 0)
+}
+
+// ReadNext is like the package-level ReadNext, but reads the sprite pointer the way ri's table stores it. idx is the index of the entry r is at, which gives the animations their kind.
+func (ri ROMInfo) ReadNext(r io.ReadSeeker, idx int) ([]Animation, error) {
+	kind := ri.SpriteKind(idx)
+
+	animPtr, err := ri.ReadPointer(r)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := animR.Seek(int64(realPtr), os.SEEK_SET); err != nil {
-		return nil, fmt.Errorf("%w while seeking sprite pointer 0x%08x", err, animPtr)
+	retOffset, err := r.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return nil, fmt.Errorf("%w while remembering offset for sprite pointer 0x%08x", err, animPtr)
 	}
 
-	anims, err := ReadAnimations(animR, int64(realPtr))
+	defer func() {
+		r.Seek(retOffset, os.SEEK_SET)
+	}()
+
+	animR, realPtr, _, err := openSprite(r, animPtr)
 	if err != nil {
-		return nil, fmt.Errorf("%w while reading sprite at sprite pointer 0x%08x", err, animPtr)
+		return nil, err
+	}
+
+	anims, err := ri.Decode.ReadAnimations(animR, realPtr)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading sprite at sprite pointer 0x%08x", checkTruncated(err), animPtr)
+	}
+
+	for i := range anims {
+		anims[i].kind = kind
+		for j := range anims[i].Frames {
+			anims[i].Frames[j].Palette = ri.Adjust.Palette(anims[i].Frames[j].Palette)
+		}
 	}
 
 	return anims, nil
 }
+
+type SpriteSet [][]Animation
+
+func (s SpriteSet) Len() int {
+	return len(s)
+}
+
+func (s SpriteSet) Sprite(i int) []Animation {
+	return s[i]
+}
+
+// withSpriteKind returns a copy of anims with every animation of the given kind. The frames are still shared.
+func withSpriteKind(anims []Animation, kind Kind) []Animation {
+	out := make([]Animation, len(anims))
+	copy(out, anims)
+	for i := range out {
+		out[i].kind = kind
+	}
+	return out
+}
@@ -0,0 +1,626 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/murkland/bnrom/paletted"
+	"github.com/murkland/bnrom/sprites/spritestest"
+)
+
+func TestColorAdjustGamma(t *testing.T) {
+	adjust := ColorAdjust{Gamma: 2}
+
+	got, ok := adjust.Color(color.RGBA{64, 128, 255, 0xff}).(color.RGBA)
+	if !ok {
+		t.Fatalf("Color returned %T, want color.RGBA", adjust.Color(color.RGBA{}))
+	}
+	if want := (color.RGBA{128, 181, 255, 0xff}); got != want {
+		t.Errorf("Color = %v, want %v", got, want)
+	}
+
+	raw, err := DecodePalette([]byte{0x00, 0x00, 0x10, 0x42, 0xff, 0x7f}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range adjust.Palette(raw) {
+		if _, ok := c.(color.RGBA); !ok {
+			t.Errorf("entry %d is %T, want color.RGBA", i, c)
+		}
+	}
+
+	// Decoding through a ROMInfo with an adjustment adjusts frame palettes, and leaves DecodePalette's raw.
+	rom := spritestest.ROM([]int{0}, oneObjectSprite(0, 0))
+	plain, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	adjusted, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1, Adjust: adjust}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite with Adjust: %s", err)
+	}
+	for i, c := range plain[0].Frames[0].Palette {
+		if got, want := adjusted[0].Frames[0].Palette[i], adjust.Color(c); got != want {
+			t.Errorf("adjusted palette entry %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestFrameOrigin(t *testing.T) {
+	rom := spritestest.ROM([]int{0}, oneObjectSprite(-4, -4))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+
+	frame := anims[0].Frames[0]
+	if origin, ok := frame.Origin(); ok || origin != (image.Point{}) {
+		t.Errorf("decoded frame origin = %s, %t, want none", origin, ok)
+	}
+	if got, want := frame.CanvasOrigin(), (image.Point{canvasSize / 2, canvasSize / 2}); got != want {
+		t.Errorf("decoded frame canvas origin = %s, want %s", got, want)
+	}
+
+	frame.SetOrigin(image.Point{3, -2})
+	if origin, ok := frame.Origin(); !ok || origin != (image.Point{3, -2}) {
+		t.Errorf("origin after SetOrigin = %s, %t, want (3,-2), true", origin, ok)
+	}
+	if got, want := frame.CanvasOrigin(), (image.Point{canvasSize/2 + 3, canvasSize/2 - 2}); got != want {
+		t.Errorf("canvas origin after SetOrigin = %s, want %s", got, want)
+	}
+}
+
+// benchFrame is a frame of a few overlapping, flipped objects, decoded from sprite data.
+func benchFrame(tb testing.TB) Frame {
+	tb.Helper()
+
+	sprite := spritestest.Sprite([]spritestest.Frame{{
+		Objects: []spritestest.Object{{Fill: 1, X: -16, Y: -16}, {Fill: 2, X: -12, Y: -12}, {Fill: 3, X: 0, Y: 0}, {Fill: 0, X: 4, Y: 4}},
+		Action:  uint16(FrameActionStop),
+	}})
+	anims, err := NewReader(bytes.NewReader(spritestest.ROM([]int{0}, sprite)), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	frame := anims[0].Frames[0]
+	frame.OAMEntries[1].Flip = FlipBoth
+	return frame
+}
+
+func TestMakeNRGBAMatchesMakeImage(t *testing.T) {
+	frame := benchFrame(t)
+
+	img, err := frame.MakeImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.NewNRGBA(img.Rect)
+	draw.Draw(want, want.Rect, img, img.Rect.Min, draw.Src)
+
+	got, err := frame.MakeNRGBA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("MakeNRGBA differs from MakeImage converted to NRGBA")
+	}
+}
+
+func BenchmarkMakeImageToNRGBA(b *testing.B) {
+	frame := benchFrame(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		img, err := frame.MakeImage()
+		if err != nil {
+			b.Fatal(err)
+		}
+		out := image.NewNRGBA(img.Rect)
+		draw.Draw(out, out.Rect, img, img.Rect.Min, draw.Src)
+	}
+}
+
+func BenchmarkMakeNRGBA(b *testing.B) {
+	frame := benchFrame(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := frame.MakeNRGBA(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRenderWalkFollowsOrigins(t *testing.T) {
+	var frames []spritestest.Frame
+	for i := 0; i < 3; i++ {
+		frames = append(frames, spritestest.Frame{Objects: []spritestest.Object{{Fill: 1, X: 0, Y: 0}}})
+	}
+	frames[len(frames)-1].Action = uint16(FrameActionLoop)
+
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite(frames))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	anim := anims[0]
+
+	// Each frame is drawn relative to its origin, which moves by minus the change in origin: (0, 0), then (-2, 0), then (-2, -3).
+	anim.Frames[0].SetOrigin(image.Pt(0, 0))
+	anim.Frames[1].SetOrigin(image.Pt(2, 0))
+	anim.Frames[2].SetOrigin(image.Pt(2, 3))
+
+	imgs, err := RenderWalk(anim)
+	if err != nil {
+		t.Fatalf("RenderWalk: %s", err)
+	}
+	if len(imgs) != 3 {
+		t.Fatalf("RenderWalk returned %d images, want 3", len(imgs))
+	}
+
+	// The frames land at (0, 0), (-4, 0) and (-4, -6), so the canvas spans (-4, -6) to (8, 8).
+	wantSize := image.Pt(12, 14)
+	for i, want := range []image.Rectangle{
+		image.Rect(4, 6, 12, 14),
+		image.Rect(0, 6, 8, 14),
+		image.Rect(0, 0, 8, 8),
+	} {
+		img := imgs[i].(*image.Paletted)
+		if got := img.Rect.Size(); got != wantSize {
+			t.Errorf("frame %d canvas is %s, want %s", i, got, wantSize)
+		}
+		if got := paletted.FindTrim(img); got != want {
+			t.Errorf("frame %d is drawn at %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestFrameAt(t *testing.T) {
+	anim := func(action FrameAction, delays ...uint16) Animation {
+		a := Animation{Speed: 1}
+		for _, d := range delays {
+			a.Frames = append(a.Frames, Frame{Delay: d})
+		}
+		a.Frames[len(a.Frames)-1].Action = action
+		return a
+	}
+	// ms is where tick ticks starts, the way a caller would compute it.
+	ms := func(ticks int) float64 {
+		return float64(TicksToDuration(ticks)) / float64(time.Millisecond)
+	}
+
+	loop := anim(FrameActionLoop, 2, 3, 1)
+	stop := anim(FrameActionStop, 2, 3, 1)
+	fast := loop
+	fast.Speed = 2
+
+	for _, tc := range []struct {
+		name string
+		anim Animation
+		ms   float64
+		want int
+	}{
+		{"start", loop, 0, 0},
+		{"before start", loop, -10, 0},
+		{"just before first boundary", loop, ms(2) - 1, 0},
+		{"first boundary", loop, ms(2), 1},
+		{"last boundary", loop, ms(5), 2},
+		{"loop end wraps", loop, ms(6), 0},
+		{"loop wraps into second frame", loop, ms(6 + 2), 1},
+		{"loop many passes later", loop, ms(6*10 + 5), 2},
+		{"stop end holds", stop, ms(6), 2},
+		{"stop long after end holds", stop, ms(600), 2},
+		{"speed scales time", fast, ms(1), 1},
+		{"no delays", anim(FrameActionLoop, 0, 0), ms(3), 1},
+	} {
+		if got, _ := tc.anim.FrameAt(tc.ms); got != tc.want {
+			t.Errorf("%s: FrameAt(%g) = %d, want %d", tc.name, tc.ms, got, tc.want)
+		}
+	}
+
+	if got, _ := (Animation{}).FrameAt(0); got != -1 {
+		t.Errorf("FrameAt on no frames = %d, want -1", got)
+	}
+}
+
+func TestDecodeTiles(t *testing.T) {
+	// Each byte holds two pixels, the low nibble first, so the first row is 1 to 8. The other rows are just different from each other.
+	data := []byte{0x21, 0x43, 0x65, 0x87}
+	for i := 4; i < 8*8/2; i++ {
+		data = append(data, byte(i*37+11))
+	}
+
+	tiles, err := DecodeTiles(data, 4)
+	if err != nil {
+		t.Fatalf("DecodeTiles: %s", err)
+	}
+	if len(tiles) != 1 {
+		t.Fatalf("DecodeTiles returned %d tiles, want 1", len(tiles))
+	}
+	tile := tiles[0]
+	if tile.Rect != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("tile is %s, want 8x8", tile.Rect)
+	}
+	for x := 0; x < 8; x++ {
+		if got := tile.ColorIndexAt(x, 0); got != uint8(x+1) {
+			t.Errorf("pixel (%d, 0) = %d, want %d", x, got, x+1)
+		}
+	}
+	for i, p := range data {
+		if lo, hi := tile.Pix[i*2], tile.Pix[i*2+1]; lo != p&0xf || hi != p>>4 {
+			t.Errorf("pixels %d, %d = %d, %d, want %d, %d from byte 0x%02x", i*2, i*2+1, lo, hi, p&0xf, p>>4, p)
+		}
+	}
+
+	wide := make([]byte, 8*8*2)
+	for i := range wide {
+		wide[i] = byte(i)
+	}
+	tiles, err = DecodeTiles(wide, 8)
+	if err != nil {
+		t.Fatalf("DecodeTiles at 8bpp: %s", err)
+	}
+	if len(tiles) != 2 || tiles[1].ColorIndexAt(7, 7) != 127 {
+		t.Errorf("8bpp tiles didn't take a byte per pixel")
+	}
+
+	if _, err := DecodeTiles(data[:len(data)-1], 4); !errors.Is(err, ErrTruncated) {
+		t.Errorf("DecodeTiles of a partial tile = %v, want ErrTruncated", err)
+	}
+	if _, err := DecodeTiles(data, 2); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("DecodeTiles at 2bpp = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestDecodeTile4bppFlips(t *testing.T) {
+	data := make([]byte, 8*8/2)
+	for i := range data {
+		data[i] = byte(i*37 + 11)
+	}
+	// want is the unflipped pixel at x, y, read straight from the nibbles.
+	want := func(x, y int) uint8 {
+		p := data[(y*8+x)/2]
+		if x%2 == 0 {
+			return p & 0xf
+		}
+		return p >> 4
+	}
+	palette := color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}}
+
+	for _, tc := range []struct {
+		flipH, flipV bool
+		src          func(x, y int) (int, int)
+	}{
+		{false, false, func(x, y int) (int, int) { return x, y }},
+		{true, false, func(x, y int) (int, int) { return 7 - x, y }},
+		{false, true, func(x, y int) (int, int) { return x, 7 - y }},
+		{true, true, func(x, y int) (int, int) { return 7 - x, 7 - y }},
+	} {
+		tile := DecodeTile4bpp(data, palette, tc.flipH, tc.flipV)
+		if tile.Rect != image.Rect(0, 0, 8, 8) {
+			t.Fatalf("flipH %t, flipV %t: tile is %s, want 8x8", tc.flipH, tc.flipV, tile.Rect)
+		}
+		if len(tile.Palette) != len(palette) {
+			t.Errorf("flipH %t, flipV %t: tile doesn't have the palette passed in", tc.flipH, tc.flipV)
+		}
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				sx, sy := tc.src(x, y)
+				if got := tile.ColorIndexAt(x, y); got != want(sx, sy) {
+					t.Errorf("flipH %t, flipV %t: pixel (%d, %d) = %d, want %d from (%d, %d)", tc.flipH, tc.flipV, x, y, got, want(sx, sy), sx, sy)
+				}
+			}
+		}
+	}
+}
+
+func TestUnionBoundsWithOrigins(t *testing.T) {
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite([]spritestest.Frame{
+		{Objects: []spritestest.Object{{Fill: 1, X: -4, Y: -4}}},
+		{Objects: []spritestest.Object{{Fill: 2, X: 0, Y: 0}}},
+		// A frame with nothing to draw doesn't stretch the bounds to its origin.
+		{Action: uint16(FrameActionLoop)},
+	}))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	anim := anims[0]
+
+	if got, want := anim.UnionBounds(), image.Rect(-4, -4, 8, 8); got != want {
+		t.Errorf("UnionBounds without origins = %s, want %s", got, want)
+	}
+
+	// Bounds are relative to each frame's own origin, so moving one shifts only that frame's contribution.
+	anim.Frames[1].SetOrigin(image.Pt(2, 3))
+	anim.Frames[2].SetOrigin(image.Pt(-20, -20))
+	if got, want := anim.UnionBounds(), image.Rect(-4, -4, 6, 5); got != want {
+		t.Errorf("UnionBounds with origins = %s, want %s", got, want)
+	}
+}
+
+func TestFrameSizes(t *testing.T) {
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite([]spritestest.Frame{
+		{Objects: []spritestest.Object{{Fill: 1, X: 0, Y: 0}}},
+		{Objects: []spritestest.Object{{Fill: 1, X: 0, Y: 0}, {Fill: 2, X: 8, Y: 0}}},
+		{},
+		// The same size somewhere else on the canvas is still the same size.
+		{Objects: []spritestest.Object{{Fill: 3, X: -30, Y: 12}}},
+		// Only opaque pixels count, so a transparent object adds nothing.
+		{Objects: []spritestest.Object{{Fill: 1, X: 0, Y: 0}, {Fill: 0, X: 0, Y: 8}}},
+		{Objects: []spritestest.Object{{Fill: 1, X: 0, Y: 0}, {Fill: 2, X: 0, Y: 8}}, Action: uint16(FrameActionStop)},
+	}))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+
+	want := []image.Point{{8, 8}, {16, 8}, {8, 16}}
+	if got := anims[0].FrameSizes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FrameSizes = %v, want %v", got, want)
+	}
+}
+
+func TestIndexHistogram(t *testing.T) {
+	tile := func(pix ...uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		copy(tile.Pix, pix)
+		return tile
+	}
+	// Object 1 overlaps the right half of object 0 and draws over it, with the second palbank.
+	frame := Frame{
+		Tiles: []*image.Paletted{tile(1, 1, 2, 0, 3), tile(0, 5, 5, 5)},
+		OAMEntries: []OAMEntry{
+			{TileIndex: 0, X: -8, Y: -8, WTiles: 1, HTiles: 1},
+			{TileIndex: 1, X: -4, Y: -8, WTiles: 1, HTiles: 1, PaletteOffset: 1},
+		},
+	}
+
+	want := map[uint8]int{
+		// Object 0's first row starts 1, 1, 2, 0.
+		1: 2,
+		2: 1,
+		// Object 1's first pixel is transparent, so object 0's 3 shows through it.
+		3:      1,
+		16 + 5: 3,
+		// Every other covered pixel: the objects cover 12x8 between them, less the 7 pixels above.
+		0: 12*8 - 7,
+	}
+	if got := frame.IndexHistogram(); !reflect.DeepEqual(got, want) {
+		t.Errorf("IndexHistogram = %v, want %v", got, want)
+	}
+}
+
+func TestTicksToDuration(t *testing.T) {
+	// The GBA refreshes at 59.7275 Hz, not 60, so a second's worth of refreshes takes a little over a second.
+	for _, tc := range []struct {
+		ticks int
+		want  time.Duration
+	}{
+		{0, 0},
+		{1, 16742706 * time.Nanosecond},
+		{60, 1004562387 * time.Nanosecond},
+		{597275, 10000 * time.Second},
+	} {
+		got := TicksToDuration(tc.ticks)
+		if diff := got - tc.want; diff < -time.Nanosecond || diff > time.Nanosecond {
+			t.Errorf("TicksToDuration(%d) = %s, want %s", tc.ticks, got, tc.want)
+		}
+	}
+
+	anim := Animation{Frames: []Frame{{Delay: 30}, {Delay: 30}}}
+	if got, want := anim.Duration(), TicksToDuration(60); got != want {
+		t.Errorf("Duration = %s, want %s", got, want)
+	}
+	anim.Speed = 2
+	if got, want := anim.Duration(), TicksToDuration(30); got != want {
+		t.Errorf("Duration at double speed = %s, want %s", got, want)
+	}
+}
+
+func TestMakeImageIntoMissingColor(t *testing.T) {
+	tile := func(fill uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = fill
+		}
+		return tile
+	}
+	red, green := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0xff, 0, 0xff}
+	frame := Frame{
+		Palette: color.Palette{color.RGBA{}, red, green},
+		Tiles:   []*image.Paletted{tile(1), tile(2)},
+		OAMEntries: []OAMEntry{
+			{TileIndex: 0, X: -8, Y: 0, WTiles: 1, HTiles: 1},
+			{TileIndex: 1, X: 0, Y: 0, WTiles: 1, HTiles: 1},
+		},
+	}
+
+	if _, err := frame.MakeImageInto(color.Palette{color.RGBA{}, red}); !errors.Is(err, ErrMissingColor) {
+		t.Errorf("MakeImageInto without green = %v, want ErrMissingColor", err)
+	}
+	if _, err := frame.MakeImageWithPalette(color.Palette{color.RGBA{}, red}); err != nil {
+		t.Errorf("MakeImageWithPalette without green = %v, want it mapped to the nearest color", err)
+	}
+
+	img, err := frame.MakeImageInto(color.Palette{color.RGBA{}, green, red})
+	if err != nil {
+		t.Fatalf("MakeImageInto with every color: %s", err)
+	}
+	origin := frame.CanvasOrigin()
+	if got := img.ColorIndexAt(origin.X-8, origin.Y); got != 2 {
+		t.Errorf("red pixel has index %d, want 2", got)
+	}
+	if got := img.ColorIndexAt(origin.X, origin.Y); got != 1 {
+		t.Errorf("green pixel has index %d, want 1", got)
+	}
+}
+
+func TestMakeImageLayer(t *testing.T) {
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite([]spritestest.Frame{{
+		Objects: []spritestest.Object{{Fill: 1, X: -16, Y: 0}, {Fill: 2, X: 0, Y: 0}, {Fill: 3, X: 16, Y: 0}},
+		Action:  uint16(FrameActionStop),
+	}}))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	frame := anims[0].Frames[0]
+	origin := frame.CanvasOrigin()
+
+	for _, tc := range []struct {
+		mask ObjectMask
+		want [3]uint8
+	}{
+		{nil, [3]uint8{1, 2, 3}},
+		{ObjectIndexes(0, 2), [3]uint8{1, 0, 3}},
+		{ObjectIndexes(1), [3]uint8{0, 2, 0}},
+		{ObjectIndexes(), [3]uint8{0, 0, 0}},
+		{func(i int, ent OAMEntry) bool { return ent.X >= 0 }, [3]uint8{0, 2, 3}},
+	} {
+		img, err := frame.MakeImageLayer(tc.mask)
+		if err != nil {
+			t.Fatalf("MakeImageLayer: %s", err)
+		}
+		for i, want := range tc.want {
+			// Each object is a solid 8x8 square, so check all of it.
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					if got := img.ColorIndexAt(origin.X-16+i*16+x, origin.Y+y); got != want {
+						t.Fatalf("want objects %v: object %d pixel (%d, %d) = %d, want %d", tc.want, i, x, y, got, want)
+					}
+				}
+			}
+		}
+		if trim := paletted.FindTrim(img); !trim.Empty() && !trim.In(image.Rect(origin.X-16, origin.Y, origin.X+24, origin.Y+8)) {
+			t.Errorf("want objects %v: drew pixels at %s, outside the objects", tc.want, trim)
+		}
+	}
+}
+
+func TestIndexAtMatchesMakeImage(t *testing.T) {
+	// The objects overlap, so the test also covers which one is on top.
+	rom := spritestest.ROM([]int{0}, spritestest.Sprite([]spritestest.Frame{{
+		Objects: []spritestest.Object{{Fill: 1, X: -8, Y: -8}, {Fill: 2, X: -4, Y: -4}, {Fill: 0, X: 0, Y: 0}},
+		Action:  uint16(FrameActionStop),
+	}}))
+	anims, err := NewReader(bytes.NewReader(rom), ROMInfo{Count: 1}).Sprite(0)
+	if err != nil {
+		t.Fatalf("Sprite: %s", err)
+	}
+	frame := anims[0].Frames[0]
+	origin := frame.CanvasOrigin()
+
+	img, err := frame.MakeImage()
+	if err != nil {
+		t.Fatalf("MakeImage: %s", err)
+	}
+	for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+		for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+			got, covered := frame.IndexAt(x-origin.X, y-origin.Y)
+			if want := img.ColorIndexAt(x, y); got != want {
+				t.Fatalf("IndexAt(%d, %d) = %d, but MakeImage drew %d", x-origin.X, y-origin.Y, got, want)
+			}
+			pt := image.Pt(x-origin.X, y-origin.Y)
+			wantCovered := pt.In(image.Rect(-8, -8, 0, 0)) || pt.In(image.Rect(-4, -4, 4, 4)) || pt.In(image.Rect(0, 0, 8, 8))
+			if covered != wantCovered {
+				t.Fatalf("IndexAt(%d, %d) reports covered = %t, want %t", x-origin.X, y-origin.Y, covered, wantCovered)
+			}
+		}
+	}
+}
+
+func TestBuildGlobalPaletteTransparency(t *testing.T) {
+	tile := func(fill uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = fill
+		}
+		return tile
+	}
+	red, green, white, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0xff, 0, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+	oam := []OAMEntry{
+		{TileIndex: 0, X: -16, Y: 0, WTiles: 1, HTiles: 1},
+		{TileIndex: 1, X: -8, Y: 0, WTiles: 1, HTiles: 1},
+		{TileIndex: 2, X: 0, Y: 0, WTiles: 1, HTiles: 1},
+	}
+	// Each palette has an opaque color at index 0, which is never drawn, and the second has a transparent entry at index 1 too.
+	a := Frame{
+		Palette:    color.Palette{blue, red, green},
+		Tiles:      []*image.Paletted{tile(0), tile(1), tile(2)},
+		OAMEntries: oam,
+	}
+	b := Frame{
+		Palette:    color.Palette{red, color.NRGBA{0, 0, 0xff, 0}, green, white},
+		Tiles:      []*image.Paletted{tile(1), tile(2), tile(3)},
+		OAMEntries: oam,
+	}
+
+	palette, err := BuildGlobalPalette([][]Animation{{{Frames: []Frame{a}}}, {{Frames: []Frame{b}}}})
+	if err != nil {
+		t.Fatalf("BuildGlobalPalette: %s", err)
+	}
+	if want := (color.Palette{color.RGBA{}, red, green, white}); !reflect.DeepEqual(palette, want) {
+		t.Fatalf("BuildGlobalPalette = %v, want %v", palette, want)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		frame Frame
+		want  [3]uint8
+	}{
+		{"first", a, [3]uint8{0, 1, 2}},
+		{"second", b, [3]uint8{0, 2, 3}},
+	} {
+		img, err := tc.frame.MakeImageInto(palette)
+		if err != nil {
+			t.Fatalf("MakeImageInto %s frame: %s", tc.name, err)
+		}
+		origin := tc.frame.CanvasOrigin()
+		for i, want := range tc.want {
+			if got := img.ColorIndexAt(origin.X-16+i*8, origin.Y); got != want {
+				t.Errorf("%s frame: object %d has index %d, want %d", tc.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestMakeImageWithoutPalette(t *testing.T) {
+	tile := func(fill uint8) *image.Paletted {
+		tile := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		for i := range tile.Pix {
+			tile.Pix[i] = fill
+		}
+		return tile
+	}
+
+	blank := Frame{
+		Tiles:      []*image.Paletted{tile(0)},
+		OAMEntries: []OAMEntry{{TileIndex: 0, X: 0, Y: 0, WTiles: 1, HTiles: 1}},
+	}
+	img, err := blank.MakeImage()
+	if err != nil {
+		t.Fatalf("MakeImage of a transparent frame without a palette: %s", err)
+	}
+	if want := (color.Palette{color.RGBA{}}); !reflect.DeepEqual(img.Palette, want) {
+		t.Errorf("MakeImage palette = %v, want %v", img.Palette, want)
+	}
+
+	opaque := blank
+	opaque.Tiles = []*image.Paletted{tile(3)}
+	if _, err := opaque.MakeImage(); !errors.Is(err, ErrMissingColor) {
+		t.Errorf("MakeImage of an opaque frame without a palette = %v, want ErrMissingColor", err)
+	}
+	if _, err := opaque.MakeImageWithPalette(color.Palette{color.RGBA{}, color.RGBA{0xff, 0, 0, 0xff}}); !errors.Is(err, ErrMissingColor) {
+		t.Errorf("MakeImageWithPalette of an opaque frame without a palette = %v, want ErrMissingColor", err)
+	}
+}
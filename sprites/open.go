@@ -0,0 +1,103 @@
+package sprites
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/murkland/gbarom"
+)
+
+// A Detector reports whether a file starting with header is in a format its decoder handles. header holds the first HeaderSize bytes, or the whole file if it's shorter.
+type Detector func(header []byte) bool
+
+// An Opener opens the file at path as an AnimationSource.
+type Opener func(path string) (AnimationSource, error)
+
+// HeaderSize is how much of the file Open passes to detectors, enough for both GBA and NDS headers.
+const HeaderSize = 0x200
+
+type decoder struct {
+	detect Detector
+	open   Opener
+}
+
+var (
+	decodersMu sync.Mutex
+	decoders   []decoder
+)
+
+// Register adds a decoder for Open to try. Decoders are tried in the order they were registered, after the built-in GBA one.
+func Register(detect Detector, open Opener) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders = append(decoders, decoder{detect, open})
+}
+
+// Open sniffs the file at path and opens it with the first registered decoder that recognizes it.
+func Open(path string) (AnimationSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(f, header)
+	f.Close()
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w while reading header of %s", err, path)
+	}
+	header = header[:n]
+
+	decodersMu.Lock()
+	ds := append([]decoder(nil), decoders...)
+	decodersMu.Unlock()
+
+	for _, d := range ds {
+		if d.detect(header) {
+			return d.open(path)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no registered decoder recognizes %s", ErrUnsupportedFormat, path)
+}
+
+func detectGBA(header []byte) bool {
+	if len(header) < 0xC0 {
+		return false
+	}
+	// 0xB2 is fixed at 0x96 in every GBA header, but a known ROM ID is enough for trimmed or patched ROMs that don't keep it.
+	if header[0xB2] == 0x96 {
+		return true
+	}
+	_, ok := KnownGames[string(header[0xAC:0xB0])]
+	return ok
+}
+
+// openGBA loads the whole ROM into memory, since an AnimationSource has no way to close a file left open behind it.
+func openGBA(path string) (AnimationSource, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bytes.NewReader(buf)
+
+	romID, err := gbarom.ReadROMID(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading rom id", err)
+	}
+
+	ri := FindROMInfo(romID)
+	if ri == nil {
+		return nil, fmt.Errorf("sprites: unsupported game %s", romID)
+	}
+
+	return NewReader(r, *ri), nil
+}
+
+func init() {
+	Register(detectGBA, openGBA)
+}
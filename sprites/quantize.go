@@ -0,0 +1,219 @@
+package sprites
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+type colorCount struct {
+	c [4]uint8
+	n int
+}
+
+type colorBox []colorCount
+
+func (b colorBox) widestChannel() (int, int) {
+	best, bestRange := 0, -1
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := 255, 0
+		for _, cc := range b {
+			v := int(cc.c[ch])
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if hi-lo > bestRange {
+			best, bestRange = ch, hi-lo
+		}
+	}
+	return best, bestRange
+}
+
+func (b colorBox) split() (colorBox, colorBox) {
+	ch, _ := b.widestChannel()
+	sort.Slice(b, func(i, j int) bool {
+		return b[i].c[ch] < b[j].c[ch]
+	})
+
+	total := 0
+	for _, cc := range b {
+		total += cc.n
+	}
+
+	// Split at the weighted median, but always leave at least one color on each side.
+	acc := 0
+	i := 1
+	for ; i < len(b)-1; i++ {
+		acc += b[i-1].n
+		if acc*2 >= total {
+			break
+		}
+	}
+	return b[:i], b[i:]
+}
+
+func (b colorBox) average() color.RGBA {
+	var sum [4]int
+	total := 0
+	for _, cc := range b {
+		for ch := 0; ch < 4; ch++ {
+			sum[ch] += int(cc.c[ch]) * cc.n
+		}
+		total += cc.n
+	}
+	return color.RGBA{uint8(sum[0] / total), uint8(sum[1] / total), uint8(sum[2] / total), uint8(sum[3] / total)}
+}
+
+// medianCut returns a palette of at most n colors for img. Fully transparent pixels always get their own entry at index 0.
+func medianCut(img image.Image, n int) color.Palette {
+	counts := map[[4]uint8]int{}
+	hasTransparent := false
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A == 0 {
+				hasTransparent = true
+				continue
+			}
+			counts[[4]uint8{c.R, c.G, c.B, c.A}]++
+		}
+	}
+
+	return medianCutCounts(counts, hasTransparent, n)
+}
+
+// medianCutCounts is medianCut over colors already counted, weighted by their counts.
+func medianCutCounts(counts map[[4]uint8]int, hasTransparent bool, n int) color.Palette {
+	var palette color.Palette
+	if hasTransparent {
+		palette = append(palette, color.RGBA{})
+		n--
+	}
+
+	if n <= 0 || len(counts) == 0 {
+		return palette
+	}
+
+	all := make(colorBox, 0, len(counts))
+	for c, cnt := range counts {
+		all = append(all, colorCount{c, cnt})
+	}
+	// Map iteration order is random, so sort to keep the output stable.
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		for ch := 0; ch < 4; ch++ {
+			if a[ch] != b[ch] {
+				return a[ch] < b[ch]
+			}
+		}
+		return false
+	})
+
+	boxes := []colorBox{all}
+	for len(boxes) < n {
+		best, bestRange := -1, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			if _, r := b.widestChannel(); r > bestRange {
+				best, bestRange = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		lo, hi := boxes[best].split()
+		boxes[best] = lo
+		boxes = append(boxes, hi)
+	}
+
+	for _, b := range boxes {
+		c := b.average()
+		palette = append(palette, color.NRGBA{c.R, c.G, c.B, c.A})
+	}
+
+	return palette
+}
+
+// BuildMasterPalette is like BuildGlobalPalette, but rather than failing when the sprites use more than n colors, it reduces them to n with median cut, weighting each color by how many pixels are drawn with it. Transparency keeps index 0 either way, and the result only depends on the sprites, so the same ROM always gets the same palette.
+func BuildMasterPalette(anims [][]Animation, n int) color.Palette {
+	// BuildGlobalPalette only fails when there are too many colors.
+	if p, err := BuildGlobalPalette(anims); err == nil && len(p) <= n {
+		return p
+	}
+
+	counts := map[[4]uint8]int{}
+	for _, spriteAnims := range anims {
+		for _, anim := range spriteAnims {
+			for _, frame := range anim.Frames {
+				for idx, cnt := range frame.IndexHistogram() {
+					if idx == 0 || int(idx) >= len(frame.Palette) {
+						continue
+					}
+					c := color.NRGBAModel.Convert(frame.Palette[idx]).(color.NRGBA)
+					if c.A == 0 {
+						continue
+					}
+					counts[[4]uint8{c.R, c.G, c.B, c.A}] += cnt
+				}
+			}
+		}
+	}
+
+	return medianCutCounts(counts, true, n)
+}
+
+// Quantize reduces img to a paletted image of at most n colors using median cut, mapping every pixel to its nearest palette entry.
+func Quantize(img image.Image, n int) *image.Paletted {
+	return Remap(img, medianCut(img, n))
+}
+
+// Remap maps every pixel of img to its nearest entry in p, such as a palette read with LoadLospec.
+func Remap(img image.Image, p color.Palette) *image.Paletted {
+	dst := image.NewPaletted(img.Bounds(), p)
+	draw.Draw(dst, dst.Rect, img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+// QuantizeDithered is like Quantize, but spreads the error using Floyd-Steinberg dithering.
+func QuantizeDithered(img image.Image, n int) *image.Paletted {
+	dst := image.NewPaletted(img.Bounds(), medianCut(img, n))
+	draw.FloydSteinberg.Draw(dst, dst.Rect, img, img.Bounds().Min)
+	return dst
+}
+
+// ColorError returns the root mean square difference between two images of the same size, per 8-bit RGBA channel.
+func ColorError(a image.Image, b image.Image) float64 {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Size() != bb.Size() || ab.Empty() {
+		return 0
+	}
+
+	var sum float64
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			for _, d := range [4]float64{
+				float64(ca.R) - float64(cb.R),
+				float64(ca.G) - float64(cb.G),
+				float64(ca.B) - float64(cb.B),
+				float64(ca.A) - float64(cb.A),
+			} {
+				sum += d * d
+			}
+		}
+	}
+
+	return math.Sqrt(sum / float64(ab.Dx()*ab.Dy()*4))
+}
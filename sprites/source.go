@@ -0,0 +1,422 @@
+package sprites
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/murkland/gbarom"
+	"golang.org/x/sync/errgroup"
+)
+
+// AnimationSource is anything sprites can be read from by index, such as a Reader over a ROM's sprite table or whatever Open returns.
+type AnimationSource interface {
+	NumSprites() int
+	Sprite(i int) ([]Animation, error)
+}
+
+// Reader decodes sprites from a ROM's sprite table on demand.
+type Reader struct {
+	r  io.ReadSeeker
+	ri ROMInfo
+
+	// ptrs caches the sprite table for BaseSprite.
+	ptrs []uint32
+}
+
+func NewReader(r io.ReadSeeker, ri ROMInfo) *Reader {
+	return &Reader{r: r, ri: ri}
+}
+
+// NewReaderAt is like NewReader, but reads at explicit offsets from r, e.g. an *os.File, instead of sharing its seek position. Readers made this way from the same r don't get in each other's way. size is the size of the ROM, which a ReaderAt can't report itself, so that pointers past its end are still caught.
+func NewReaderAt(r io.ReaderAt, size int64, ri ROMInfo) *Reader {
+	return NewReader(io.NewSectionReader(r, 0, size), ri)
+}
+
+// Read reads every sprite in the sprite table of the ROM r holds, which is size bytes long, decoding sprites on one goroutine per CPU. Sprites that fail with a decode error are left nil. Sprites whose entry points at the same data as an earlier one, as BaseSprite finds, share that sprite's frames instead of being decoded again, but still get the kind of their own index. If the table runs past the end of the ROM, every sprite is decoded on its own without sharing.
+//
+// Each goroutine reads through its own NewReaderAt, so r only needs to allow concurrent ReadAt calls, as *os.File and *bytes.Reader do. Read never writes to r or to any state outside the SpriteSet it returns, so several Reads can share one r at once. Sprites that share data share the same Frames, so changing a frame of one in place changes it for the others too.
+func Read(r io.ReaderAt, size int64, ri ROMInfo) (SpriteSet, error) {
+	// bases is nil if the sprite table can't be read as a whole.
+	bases := make([]int, ri.Count)
+	sr := NewReaderAt(r, size, ri)
+	for i := range bases {
+		base, err := sr.BaseSprite(i)
+		if err != nil {
+			if !IsDecodeError(err) {
+				return nil, err
+			}
+			bases = nil
+			break
+		}
+		bases[i] = base
+	}
+
+	s := make(SpriteSet, ri.Count)
+	next := make(chan int)
+
+	g, ctx := errgroup.WithContext(context.Background())
+	for w := 0; w < runtime.NumCPU(); w++ {
+		g.Go(func() error {
+			sr := NewReaderAt(r, size, ri)
+			for i := range next {
+				anims, err := sr.Sprite(i)
+				if err != nil {
+					if IsDecodeError(err) {
+						continue
+					}
+					return err
+				}
+				// Every sprite goes to exactly one goroutine, so no two of them write the same element.
+				s[i] = anims
+			}
+			return nil
+		})
+	}
+
+feed:
+	for i := range s {
+		if bases != nil && bases[i] != i {
+			continue
+		}
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for i, base := range bases {
+		if base != i && s[base] != nil {
+			s[i] = withSpriteKind(s[base], ri.SpriteKind(i))
+		}
+	}
+	return s, nil
+}
+
+// ReadFile reads every sprite of the ROM at path, straight from the file rather than loading the whole ROM into memory first.
+func ReadFile(path string) (SpriteSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	romID, err := gbarom.ReadROMID(io.NewSectionReader(f, 0, fi.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading rom id", err)
+	}
+
+	ri := FindROMInfo(romID)
+	if ri == nil {
+		return nil, fmt.Errorf("sprites: unsupported game %s", romID)
+	}
+
+	return Read(f, fi.Size(), *ri)
+}
+
+func (r *Reader) NumSprites() int {
+	return r.ri.Count
+}
+
+func (r *Reader) Sprite(i int) ([]Animation, error) {
+	if i < 0 || i >= r.ri.Count {
+		return nil, fmt.Errorf("%w: sprite %d", ErrOutOfRange, i)
+	}
+
+	if _, err := r.r.Seek(r.ri.Offset+int64(i)*r.ri.EntrySize(), os.SEEK_SET); err != nil {
+		return nil, fmt.Errorf("%w while seeking to sprite %d", err, i)
+	}
+
+	anims, err := r.ri.ReadNext(r.r, i)
+	if err != nil {
+		return nil, fmt.Errorf("%w while reading sprite %d", err, i)
+	}
+
+	return anims, nil
+}
+
+// BaseSprite returns the first sprite whose table entry points at the same data as sprite i, or i itself if there's none. Some tables point several entries at one sprite's data, so those decode to exactly the same animations. Null entries aren't treated as sharing anything.
+func (r *Reader) BaseSprite(i int) (int, error) {
+	if i < 0 || i >= r.ri.Count {
+		return 0, fmt.Errorf("%w: sprite %d", ErrOutOfRange, i)
+	}
+
+	if r.ptrs == nil {
+		// Entries pointing outside the ROM fail when they're decoded, but can still be compared.
+		ptrs, err := r.ri.PointerTable(r.r)
+		var ptErr *PointerTableError
+		if err != nil && !errors.As(err, &ptErr) {
+			return 0, err
+		}
+		r.ptrs = ptrs
+	}
+
+	if r.ptrs[i] == r.ri.PointerBase {
+		return i, nil
+	}
+	for j := 0; j < i; j++ {
+		if r.ptrs[j] == r.ptrs[i] {
+			return j, nil
+		}
+	}
+	return i, nil
+}
+
+// NumAnimations returns how many animations sprite i has, reading just the sprite's header instead of decoding its frames. Compressed sprites still have to be decompressed first.
+func (r *Reader) NumAnimations(i int) (int, error) {
+	if i < 0 || i >= r.ri.Count {
+		return 0, fmt.Errorf("%w: sprite %d", ErrOutOfRange, i)
+	}
+
+	if _, err := r.r.Seek(r.ri.Offset+int64(i)*r.ri.EntrySize(), os.SEEK_SET); err != nil {
+		return 0, fmt.Errorf("%w while seeking to sprite %d", err, i)
+	}
+
+	animPtr, err := r.ri.ReadPointer(r.r)
+	if err != nil {
+		return 0, fmt.Errorf("%w while reading sprite pointer %d", err, i)
+	}
+
+	animR, _, _, err := openSprite(r.r, animPtr)
+	if err != nil {
+		return 0, fmt.Errorf("%w while reading sprite %d", err, i)
+	}
+
+	var header [4]uint8
+	if _, err := io.ReadFull(animR, header[:]); err != nil {
+		return 0, fmt.Errorf("%w while reading header of sprite %d", checkTruncated(err), i)
+	}
+
+	// The animation count comes after 3 bytes of per-sprite header, as in ReadAnimations.
+	return int(header[3]), nil
+}
+
+// Iterate decodes the sprites in the sprite table one at a time and passes each to fn, so callers don't need to hold the whole table in memory. Sprites that fail with a decode error are skipped. If fn returns an error, iteration stops and Iterate returns it.
+func Iterate(r io.ReadSeeker, ri ROMInfo, fn func(idx int, anims []Animation) error) error {
+	sr := NewReader(r, ri)
+
+	for i := 0; i < sr.NumSprites(); i++ {
+		anims, err := sr.Sprite(i)
+		if err != nil {
+			if IsDecodeError(err) {
+				continue
+			}
+			return err
+		}
+
+		if err := fn(i, anims); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PointerTable returns the raw entries of the sprite table at tableOffset, flags included, as ReadNext would follow them. If any non-null entry doesn't point into the ROM, it fails with a *PointerTableError listing them, but still returns the whole table.
+func PointerTable(r io.ReadSeeker, tableOffset int64, count int) ([]uint32, error) {
+	return ROMInfo{Offset: tableOffset, Count: count}.PointerTable(r)
+}
+
+// PointerTable is like the package-level PointerTable, but reads ri's table the way it stores pointers.
+func (ri ROMInfo) PointerTable(r io.ReadSeeker) ([]uint32, error) {
+	size, err := r.Seek(0, os.SEEK_END)
+	if err != nil {
+		return nil, fmt.Errorf("%w while finding ROM size", err)
+	}
+
+	if _, err := r.Seek(ri.Offset, os.SEEK_SET); err != nil {
+		return nil, fmt.Errorf("%w while seeking to sprite table", err)
+	}
+
+	ptrs := make([]uint32, ri.Count)
+	var bad []int
+	for i := range ptrs {
+		ptr, err := ri.ReadPointer(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w while reading sprite pointer %d", err, i)
+		}
+		ptrs[i] = ptr
+
+		if ptr == ri.PointerBase {
+			continue
+		}
+		if ptr&0x08000000 == 0 || int64(ptr&^uint32(0x88000000)) >= size {
+			bad = append(bad, i)
+		}
+	}
+
+	if len(bad) > 0 {
+		return ptrs, &PointerTableError{bad}
+	}
+	return ptrs, nil
+}
+
+// PaletteCount is one distinct palette found by CountPalettes, with how many frames and sprites use it.
+type PaletteCount struct {
+	Palette color.Palette
+	Frames  int
+	Sprites int
+}
+
+// CountPalettes gathers the distinct frame palettes of every sprite in the sprite table at tableOffset, in the order they're first seen. Palettes count as the same if every entry has the same color, with all transparent entries alike. Sprites that fail with a decode error are skipped.
+func CountPalettes(r io.ReadSeeker, tableOffset int64, count int) ([]PaletteCount, error) {
+	var counts []PaletteCount
+	byKey := map[string]int{}
+
+	if err := Iterate(r, ROMInfo{Offset: tableOffset, Count: count}, func(idx int, anims []Animation) error {
+		seen := map[int]bool{}
+		for _, anim := range anims {
+			for _, frame := range anim.Frames {
+				if len(frame.Palette) == 0 {
+					continue
+				}
+
+				key := make([]byte, 0, len(frame.Palette)*4)
+				for _, c := range frame.Palette {
+					rgba := paletteKey(c)
+					key = append(key, rgba.R, rgba.G, rgba.B, rgba.A)
+				}
+
+				i, ok := byKey[string(key)]
+				if !ok {
+					i = len(counts)
+					byKey[string(key)] = i
+					counts = append(counts, PaletteCount{Palette: frame.Palette})
+				}
+
+				counts[i].Frames++
+				if !seen[i] {
+					seen[i] = true
+					counts[i].Sprites++
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// AllPalettes is like CountPalettes, but only returns the palettes.
+func AllPalettes(r io.ReadSeeker, tableOffset int64, count int) ([]color.Palette, error) {
+	counts, err := CountPalettes(r, tableOffset, count)
+	if err != nil {
+		return nil, err
+	}
+
+	palettes := make([]color.Palette, len(counts))
+	for i, pc := range counts {
+		palettes[i] = pc.Palette
+	}
+	return palettes, nil
+}
+
+// Hash returns a digest of everything that affects how a sprite renders and animates.
+func Hash(anims []Animation) [sha256.Size]byte {
+	h := sha256.New()
+
+	binary.Write(h, binary.LittleEndian, uint32(len(anims)))
+	for _, anim := range anims {
+		binary.Write(h, binary.LittleEndian, uint32(len(anim.Frames)))
+		for _, frame := range anim.Frames {
+			binary.Write(h, binary.LittleEndian, frame.Delay)
+			binary.Write(h, binary.LittleEndian, frame.Action)
+
+			binary.Write(h, binary.LittleEndian, uint32(len(frame.Palette)))
+			for _, c := range frame.Palette {
+				binary.Write(h, binary.LittleEndian, color.RGBAModel.Convert(c).(color.RGBA))
+			}
+
+			binary.Write(h, binary.LittleEndian, uint32(len(frame.Tiles)))
+			for _, tile := range frame.Tiles {
+				h.Write(tile.Pix)
+			}
+
+			binary.Write(h, binary.LittleEndian, uint32(len(frame.OAMEntries)))
+			for _, ent := range frame.OAMEntries {
+				binary.Write(h, binary.LittleEndian, [7]int32{
+					int32(ent.TileIndex),
+					int32(ent.X),
+					int32(ent.Y),
+					int32(ent.WTiles),
+					int32(ent.HTiles),
+					int32(ent.PaletteOffset),
+					int32(ent.Flip),
+				})
+			}
+
+			// Left out when absent, so decoded sprites hash as they did before frames could carry origins.
+			if origin, ok := frame.Origin(); ok {
+				binary.Write(h, binary.LittleEndian, [2]int32{int32(origin.X), int32(origin.Y)})
+			}
+		}
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func spriteHash(src AnimationSource, i int) ([]byte, error) {
+	if i >= src.NumSprites() {
+		return nil, nil
+	}
+
+	anims, err := src.Sprite(i)
+	if err != nil {
+		if IsDecodeError(err) {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+
+	sum := Hash(anims)
+	return sum[:], nil
+}
+
+// Diff returns the indexes of sprites that differ between a and b. A sprite that only exists in one of them, or only decodes in one of them, counts as differing.
+func Diff(a, b AnimationSource) ([]int, error) {
+	n := a.NumSprites()
+	if b.NumSprites() > n {
+		n = b.NumSprites()
+	}
+
+	var changed []int
+	for i := 0; i < n; i++ {
+		ha, err := spriteHash(a, i)
+		if err != nil {
+			return nil, err
+		}
+
+		hb, err := spriteHash(b, i)
+		if err != nil {
+			return nil, err
+		}
+
+		if ha == nil || hb == nil || !bytes.Equal(ha, hb) {
+			changed = append(changed, i)
+		}
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,203 @@
+package sprites
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/murkland/bnrom/sprites/spritestest"
+)
+
+// oneObjectSprite is the data of a sprite with one animation of one frame, drawn as one 8x8 object at x, y.
+func oneObjectSprite(x, y int8) []byte {
+	return spritestest.Sprite([]spritestest.Frame{{
+		Objects: []spritestest.Object{{Fill: 1, X: x, Y: y}},
+		Action:  uint16(FrameActionStop),
+	}})
+}
+
+func TestNewReaderAtCatchesPointersPastEnd(t *testing.T) {
+	data := make([]byte, 16)
+	binary.LittleEndian.PutUint32(data[0:], 0x08000008)
+	binary.LittleEndian.PutUint32(data[4:], 0x08001000)
+
+	sr := NewReaderAt(bytes.NewReader(data), int64(len(data)), ROMInfo{Offset: 0, Count: 2})
+	_, err := sr.ri.PointerTable(sr.r)
+
+	var ptErr *PointerTableError
+	if !errors.As(err, &ptErr) {
+		t.Fatalf("PointerTable error = %v, want a *PointerTableError", err)
+	}
+	if len(ptErr.Bad) != 1 || ptErr.Bad[0] != 1 {
+		t.Errorf("bad entries = %v, want [1]", ptErr.Bad)
+	}
+}
+
+func TestReadAliasGetsOwnKind(t *testing.T) {
+	rom := spritestest.ROM([]int{0, 0}, oneObjectSprite(0, 0))
+	ri := ROMInfo{Count: 2, Kinds: []KindRange{{Start: 0, Count: 1, Kind: Battle}, {Start: 1, Count: 1, Kind: Overworld}}}
+
+	s, err := Read(bytes.NewReader(rom), int64(len(rom)), ri)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+
+	if len(s[0]) != 1 || len(s[1]) != 1 {
+		t.Fatalf("sprites have %d and %d animations, want 1 each", len(s[0]), len(s[1]))
+	}
+	if k := s[0][0].Kind(); k != Battle {
+		t.Errorf("sprite 0 kind = %s, want %s", k, Battle)
+	}
+	if k := s[1][0].Kind(); k != Overworld {
+		t.Errorf("aliased sprite 1 kind = %s, want %s", k, Overworld)
+	}
+}
+
+func TestReadTruncatedTable(t *testing.T) {
+	rom := spritestest.ROM([]int{0, -1}, oneObjectSprite(0, 0))
+	// The table claims more entries than the ROM has room for.
+	ri := ROMInfo{Count: len(rom)/4 + 2}
+
+	s, err := Read(bytes.NewReader(rom), int64(len(rom)), ri)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if len(s[0]) != 1 {
+		t.Errorf("sprite 0 has %d animations, want 1", len(s[0]))
+	}
+	if s[len(s)-1] != nil {
+		t.Errorf("entry past the end of the ROM decoded to %v", s[len(s)-1])
+	}
+}
+
+func TestReaderSpriteKind(t *testing.T) {
+	sprite := oneObjectSprite(0, 0)
+	rom := spritestest.ROM([]int{0, 1, 2}, sprite, sprite, sprite)
+	ri := ROMInfo{Count: 3, Kinds: []KindRange{{Start: 1, Count: 1, Kind: Effect}}}
+
+	sr := NewReader(bytes.NewReader(rom), ri)
+	for i, want := range []Kind{UnknownKind, Effect, UnknownKind} {
+		anims, err := sr.Sprite(i)
+		if err != nil {
+			t.Fatalf("Sprite(%d): %s", i, err)
+		}
+		if k := anims[0].Kind(); k != want {
+			t.Errorf("sprite %d kind = %s, want %s", i, k, want)
+		}
+	}
+}
+
+func TestReadThreeBytePointers(t *testing.T) {
+	sprite := oneObjectSprite(-4, -4)
+
+	// Three 3-byte entries, the middle one null, then the sprite data they point at.
+	rom := make([]byte, 12, 12+len(sprite))
+	rom = append(rom, sprite...)
+	for _, i := range []int{0, 2} {
+		rom[i*3] = 12
+	}
+	ri := ROMInfo{Count: 3, PointerBytes: 3, PointerBase: 0x08000000}
+
+	s, err := Read(bytes.NewReader(rom), int64(len(rom)), ri)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	for i, want := range []int{1, 0, 1} {
+		if len(s[i]) != want {
+			t.Errorf("sprite %d has %d animations, want %d", i, len(s[i]), want)
+		}
+	}
+	if len(s[2]) == 1 && s[2][0].Frames[0].OAMEntries[0].X != -4 {
+		t.Errorf("sprite 2 object is at x %d, want -4", s[2][0].Frames[0].OAMEntries[0].X)
+	}
+}
+
+func TestCountPalettesShared(t *testing.T) {
+	// other is a ramp of greens, where the default palette is a ramp of reds.
+	other := make([]uint16, 16)
+	for i := range other {
+		other[i] = uint16(i) << 5
+	}
+	frame := func(palette []uint16, action FrameAction) spritestest.Frame {
+		return spritestest.Frame{Objects: []spritestest.Object{{Fill: 1}}, Palette: palette, Action: uint16(action)}
+	}
+
+	rom := spritestest.ROM([]int{0, 1, -1, 2},
+		spritestest.Sprite([]spritestest.Frame{frame(nil, FrameActionNext), frame(nil, FrameActionStop)}),
+		spritestest.Sprite([]spritestest.Frame{frame(nil, FrameActionStop)}, []spritestest.Frame{frame(other, FrameActionStop)}),
+		spritestest.Sprite([]spritestest.Frame{frame(other, FrameActionStop)}),
+	)
+
+	counts, err := CountPalettes(bytes.NewReader(rom), 0, 4)
+	if err != nil {
+		t.Fatalf("CountPalettes: %s", err)
+	}
+	if len(counts) != 2 {
+		t.Fatalf("found %d palettes, want 2", len(counts))
+	}
+	for i, want := range []PaletteCount{{Frames: 3, Sprites: 2}, {Frames: 2, Sprites: 2}} {
+		if counts[i].Frames != want.Frames || counts[i].Sprites != want.Sprites {
+			t.Errorf("palette %d is used by %d frames of %d sprites, want %d frames of %d sprites", i, counts[i].Frames, counts[i].Sprites, want.Frames, want.Sprites)
+		}
+	}
+
+	palettes, err := AllPalettes(bytes.NewReader(rom), 0, 4)
+	if err != nil {
+		t.Fatalf("AllPalettes: %s", err)
+	}
+	if len(palettes) != 2 {
+		t.Errorf("AllPalettes found %d palettes, want 2", len(palettes))
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	// placeholder is the kind of sprite some tables fill unused slots with: a frame that draws nothing.
+	placeholder := spritestest.Sprite([]spritestest.Frame{{Action: uint16(FrameActionStop)}})
+	rom := spritestest.ROM([]int{0, -1, 1}, oneObjectSprite(0, 0), placeholder)
+
+	s, err := Read(bytes.NewReader(rom), int64(len(rom)), ROMInfo{Count: 3})
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if s[1] != nil {
+		t.Errorf("null entry decoded to %v", s[1])
+	}
+	for i, want := range []bool{false, true, true} {
+		if got := IsEmpty(s[i]); got != want {
+			t.Errorf("IsEmpty(sprite %d) = %t, want %t", i, got, want)
+		}
+	}
+}
+
+func TestReadCompressed(t *testing.T) {
+	frames := func(compressed bool) []spritestest.Frame {
+		return []spritestest.Frame{
+			{Objects: []spritestest.Object{{Fill: 1, X: -8, Y: -8}, {Fill: 2, X: 0, Y: -8}}, Compressed: compressed},
+			{Objects: []spritestest.Object{{Fill: 3, X: -4, Y: 0}}, Action: uint16(FrameActionLoop), Compressed: compressed},
+		}
+	}
+	plain := spritestest.Sprite(frames(false))
+	rom := spritestest.ROM([]int{0, 1, 2}, plain, spritestest.CompressedSprite(plain), spritestest.Sprite(frames(true)))
+
+	s, err := Read(bytes.NewReader(rom), int64(len(rom)), ROMInfo{Count: 3})
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	for i, name := range []string{"plain", "compressed", "compressed tiles"} {
+		if len(s[i]) != 1 || len(s[i][0].Frames) != 2 {
+			t.Fatalf("%s sprite decoded to %v, want one animation of 2 frames", name, s[i])
+		}
+		for j := range s[i][0].Frames {
+			got, want := &s[i][0].Frames[j], &s[0][0].Frames[j]
+			if !reflect.DeepEqual(got.Tiles, want.Tiles) || !reflect.DeepEqual(got.Palette, want.Palette) || !reflect.DeepEqual(got.OAMEntries, want.OAMEntries) {
+				t.Errorf("%s sprite frame %d differs from the plain one", name, j)
+			}
+			// Only tiles compressed on their own count; a compressed sprite's tiles are plain once it's decompressed.
+			if wantCompressed := i == 2; got.Compressed() != wantCompressed {
+				t.Errorf("%s sprite frame %d Compressed() = %t, want %t", name, j, got.Compressed(), wantCompressed)
+			}
+		}
+	}
+}
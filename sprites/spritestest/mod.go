@@ -0,0 +1,161 @@
+package spritestest
+
+import (
+	"encoding/binary"
+)
+
+// Object is one 8x8 object of a Frame, whose one tile is filled with palette index Fill, at OAM position X, Y.
+type Object struct {
+	Fill uint8
+	X, Y int8
+}
+
+// Frame is one frame for Sprite. Palette is its 16 BGR555 colors, or nil for a ramp of reds with every index a different one. Compressed stores its tiles LZ77 compressed instead of after a byte size.
+type Frame struct {
+	Objects    []Object
+	Palette    []uint16
+	Delay      uint16
+	Action     uint16
+	Compressed bool
+}
+
+// Sprite returns the data of a sprite with one animation per element of anims, laid out the way sprites.ReadAnimations reads it.
+func Sprite(anims ...[]Frame) []byte {
+	// Pointers in sprite data are relative to 4 bytes into it, past the header and animation count.
+	var body []byte
+	ptr := func() uint32 { return uint32(len(body)) }
+	put32 := func(v uint32) {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], v)
+		body = append(body, b[:]...)
+	}
+
+	numFrames := 0
+	for _, anim := range anims {
+		numFrames += len(anim)
+	}
+
+	animsPtr := ptr()
+	for range anims {
+		put32(0)
+	}
+	framesPtr := ptr()
+	body = append(body, make([]byte, numFrames*20)...)
+
+	fi := 0
+	for ai, anim := range anims {
+		binary.LittleEndian.PutUint32(body[animsPtr+uint32(ai)*4:], framesPtr+uint32(fi)*20)
+
+		for _, f := range anim {
+			tilesPtr := ptr()
+			var tiles []byte
+			for _, obj := range f.Objects {
+				for i := 0; i < 8*8/2; i++ {
+					tiles = append(tiles, obj.Fill|obj.Fill<<4)
+				}
+			}
+			if f.Compressed {
+				body = append(body, LZ77(tiles)...)
+			} else {
+				put32(uint32(len(tiles)))
+				body = append(body, tiles...)
+			}
+
+			palPtr := ptr()
+			put32(16 * 2)
+			for i := 0; i < 16; i++ {
+				c := uint16(2 * i)
+				if f.Palette != nil {
+					c = f.Palette[i]
+				}
+				body = append(body, uint8(c), uint8(c>>8))
+			}
+			// A palbank starting with 4 ends the palette.
+			put32(4)
+			body = append(body, make([]byte, 16*2-4)...)
+
+			oamPtrPtr := ptr()
+			put32(4)
+			for i, obj := range f.Objects {
+				body = append(body, uint8(i), uint8(obj.X), uint8(obj.Y), 0, 0)
+			}
+			body = append(body, 0xff, 0, 0, 0, 0)
+
+			rec := body[framesPtr+uint32(fi)*20:]
+			binary.LittleEndian.PutUint32(rec[0:], tilesPtr)
+			binary.LittleEndian.PutUint32(rec[4:], palPtr)
+			binary.LittleEndian.PutUint32(rec[12:], oamPtrPtr)
+			binary.LittleEndian.PutUint16(rec[16:], f.Delay)
+			binary.LittleEndian.PutUint16(rec[18:], f.Action)
+			fi++
+		}
+	}
+
+	return append([]byte{0, 0, 0, uint8(len(anims))}, body...)
+}
+
+// LZ77 returns data compressed the way the GBA BIOS decompresses it: the 0x10 header byte and the 24-bit size, then every byte as a literal, padded to a multiple of 4 bytes.
+func LZ77(data []byte) []byte {
+	out := []byte{0x10, uint8(len(data)), uint8(len(data) >> 8), uint8(len(data) >> 16)}
+	for i := 0; i < len(data); i += 8 {
+		end := i + 8
+		if end > len(data) {
+			end = len(data)
+		}
+		// A zero flag byte makes the next 8 bytes all literals.
+		out = append(out, 0)
+		out = append(out, data[i:end]...)
+	}
+	for len(out)%4 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// CompressedSprite returns the data a compressed sprite pointer points at for sprite, which is LZ77 compressed after a 4-byte word the decoder skips.
+func CompressedSprite(sprite []byte) []byte {
+	return LZ77(append(make([]byte, 4), sprite...))
+}
+
+// ROM returns a ROM with a table of 4-byte sprite pointers at offset 0, whose entry i points at sprites[table[i]], or is null if table[i] is -1. Pointers to sprites from CompressedSprite get the compressed flag.
+func ROM(table []int, sprites ...[]byte) []byte {
+	return romAt(0, table, sprites)
+}
+
+// HeaderSize is the size of the cartridge header GameROM starts with, which is where its sprite table starts.
+const HeaderSize = 0xC0
+
+// GameROM is like ROM, but starts with a cartridge header giving the 4-character ROM ID romID, and puts the table after it at HeaderSize.
+func GameROM(romID string, table []int, sprites ...[]byte) []byte {
+	rom := romAt(HeaderSize, table, sprites)
+	copy(rom[0xAC:0xB0], romID)
+	return rom
+}
+
+func romAt(tableOffset int, table []int, sprites [][]byte) []byte {
+	rom := make([]byte, tableOffset+len(table)*4)
+
+	offsets := make([]int, len(sprites))
+	for i, s := range sprites {
+		// Sprite data is read with 32-bit pointers, so keep it aligned.
+		for len(rom)%4 != 0 {
+			rom = append(rom, 0)
+		}
+		offsets[i] = len(rom)
+		rom = append(rom, s...)
+	}
+
+	for i, si := range table {
+		if si < 0 {
+			continue
+		}
+		ptr := 0x08000000 | uint32(offsets[si])
+		// Sprites from Sprite start with a zero header byte, so only compressed ones can start with 0x10.
+		if len(sprites[si]) > 0 && sprites[si][0] == 0x10 {
+			ptr |= 0x80000000
+		}
+		binary.LittleEndian.PutUint32(rom[tableOffset+i*4:], ptr)
+	}
+
+	return rom
+}